@@ -0,0 +1,552 @@
+package lager
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// AuditScopeName the scope of the audit log.
+	AuditScopeName = "@audit"
+
+	// the keys of the fields chaining the audit entries.
+	AuditSeqKey      = "seq"
+	AuditPrevHashKey = "prev_hash"
+	AuditHashKey     = "hash"
+
+	// the maximum size of the last entry of an audit log resumed by the Configure.
+	maxAuditEntrySize = 1 << 20
+
+	// auditTruncatedKey the key of the field of the entry recording the torn entry truncated by the resume.
+	auditTruncatedKey = "truncated_bytes"
+
+	// auditBackupTimeLayout the layout of the time of the rotated audit log files, e.g. audit-2022-09-01T08-00-00.000.log.
+	auditBackupTimeLayout = "2006-01-02T15-04-05.000"
+)
+
+var (
+	auditScope = RegisterScope(AuditScopeName, "the tamper-evident audit log")
+
+	// auditHashPrefix precedes the hash of an audit entry, which is the last field of the JSON object.
+	auditHashPrefix = []byte(`,"` + AuditHashKey + `":"`)
+
+	// auditChains the chains of the audit logs by their paths and keys, which outlive the Configure,
+	// so that the cores of the successive Configures continue the same chain rather than forking it.
+	auditChains   = make(map[auditChainID]*auditChain)
+	auditChainsMu sync.Mutex
+)
+
+type (
+	// AuditConfig the audit log, where every entry of the audit scope is chained to the previous one.
+	AuditConfig struct {
+		// the path of the audit log file, which is rotated like the RotateOutputPath.
+		Path string
+
+		// the key of the HMAC-SHA256 chaining the entries.
+		Key []byte
+
+		// the rotation of the audit log file, see the RotationMaxSize, RotationMaxAge and RotationMaxBackups,
+		// the default size is the one of the options, whereas the rotated files are kept forever by default,
+		// since the chain is verified from its start, and are removed only by the age or the number set here.
+		RotationMaxSize    int
+		RotationMaxAge     int
+		RotationMaxBackups int
+	}
+
+	// AuditCheckpoint the sequence number and the hash of an audit entry, which anchors the verification of
+	// the audit log, see the VerifyAuditLogFrom.
+	AuditCheckpoint struct {
+		Seq  uint64
+		Hash string
+	}
+
+	// auditChainID identifies the chain of an audit log.
+	auditChainID struct {
+		path string
+		key  string
+	}
+
+	// auditChain the state of the hash chain of an audit log, shared by the auditCores of all the Configures.
+	auditChain struct {
+		mu   sync.Mutex
+		key  []byte
+		path string
+		// sink the audit log opened by the latest Configure, nil once closed.
+		sink *rotateSink
+		seq  uint64
+		prev string
+		// torn the size of the torn last entry truncated by the resume, which is recorded by the next entry.
+		torn int64
+	}
+
+	// auditCore writes the entries to the audit log with the sequence number and the hash chain.
+	auditCore struct {
+		enc   zapcore.Encoder
+		chain *auditChain
+		// sink the audit log opened by the Configure of the core.
+		sink *rotateSink
+	}
+
+	// auditRoutingCore writes the entries of the audit scope to the audit core only,
+	// bypassing the dedup, the redaction and the other outputs, and the others to the wrapped core.
+	auditRoutingCore struct {
+		zapcore.Core
+		audit zapcore.Core
+	}
+
+	// auditRecord the chaining fields of an audit entry.
+	auditRecord struct {
+		Seq  uint64 `json:"seq"`
+		Prev string `json:"prev_hash"`
+	}
+)
+
+// AuditScope returns the scope of the audit log, whose entries are written to the audit log of the WithAudit,
+// and are never sampled, rate limited, deduplicated or redacted.
+func AuditScope() *Scope {
+	return auditScope
+}
+
+// newAuditCore opens the audit log, and continues its chain, which is resumed from the last entry of the log
+// by the first Configure of the process.
+func newAuditCore(cfg AuditConfig, options *Options) (*auditCore, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("no path of the audit log")
+	}
+	if len(cfg.Key) == 0 {
+		return nil, errors.New("no key of the audit log")
+	}
+
+	chain, err := openAuditChain(cfg.Path, cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	maxSize := cfg.RotationMaxSize
+	if maxSize <= 0 {
+		maxSize = options.RotationMaxSize
+	}
+	sink := newRotateSink(cfg.Path, maxSize, cfg.RotationMaxAge, cfg.RotationMaxBackups)
+
+	// the cores of the previous Configure write to the new sink as well until they're closed.
+	chain.mu.Lock()
+	chain.sink = sink
+	torn := chain.torn
+	chain.torn = 0
+	chain.mu.Unlock()
+
+	core := &auditCore{enc: zapcore.NewJSONEncoder(defaultEncoderConfig()), chain: chain, sink: sink}
+	if torn > 0 {
+		e := zapcore.Entry{
+			Level:      zapcore.WarnLevel,
+			Time:       time.Now(),
+			LoggerName: AuditScopeName,
+			Message:    "truncated the torn last entry of the audit log",
+		}
+		if err := core.Write(e, []zapcore.Field{zap.Int64(auditTruncatedKey, torn)}); err != nil {
+			_ = core.Close()
+			return nil, err
+		}
+	}
+
+	return core, nil
+}
+
+// openAuditChain returns the chain of the audit log, which is resumed if it's the first one of the path and the key.
+func openAuditChain(path string, key []byte) (*auditChain, error) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	id := auditChainID{path: path, key: string(key)}
+
+	auditChainsMu.Lock()
+	defer auditChainsMu.Unlock()
+
+	if chain, ok := auditChains[id]; ok {
+		return chain, nil
+	}
+
+	chain := &auditChain{key: append([]byte(nil), key...), path: path}
+	if err := chain.resume(); err != nil {
+		return nil, err
+	}
+
+	auditChains[id] = chain
+	return chain, nil
+}
+
+// resume restores the sequence number and the hash of the last entry of the audit log, or of its newest rotated file
+// if it's empty, e.g. just rotated. the torn last entry, which a crash leaves without the line ending, is truncated.
+// the last entry, which fails the verification otherwise, fails the resume, since the log may be tampered with.
+func (c *auditChain) resume() error {
+	torn, err := truncateTorn(c.path, maxAuditEntrySize)
+	if err != nil {
+		return err
+	}
+
+	path := c.path
+	line, err := lastLine(path, maxAuditEntrySize)
+	if err != nil {
+		return err
+	}
+
+	if len(line) == 0 {
+		backups, err := auditBackups(c.path)
+		if err != nil || len(backups) == 0 {
+			return err
+		}
+
+		path = backups[len(backups)-1]
+		if line, err = lastLine(path, maxAuditEntrySize); err != nil || len(line) == 0 {
+			return err
+		}
+	}
+
+	rec, hash, err := c.parse(line)
+	if err != nil {
+		return errors.Wrapf(err, "failed to resume the audit log %q, "+
+			"which must be restored, or moved aside to start a new chain", path)
+	}
+
+	c.seq, c.prev, c.torn = rec.Seq, hash, torn
+	return nil
+}
+
+// sign returns the hex-encoded HMAC of the data.
+func (c *auditChain) sign(data []byte) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parse returns the chaining fields and the hash of an audit entry, whose hash is verified.
+func (c *auditChain) parse(line []byte) (auditRecord, string, error) {
+	var rec auditRecord
+
+	i := bytes.LastIndex(line, auditHashPrefix)
+	if i < 0 || !bytes.HasSuffix(line, []byte(`"}`)) {
+		return rec, "", errors.New("no hash of the entry")
+	}
+
+	hash := string(line[i+len(auditHashPrefix) : len(line)-2])
+	signed := append(append(make([]byte, 0, i+1), line[:i]...), '}')
+	if !hmac.Equal([]byte(hash), []byte(c.sign(signed))) {
+		return rec, "", errors.New("the hash of the entry mismatches, the entry is modified")
+	}
+
+	if err := json.Unmarshal(signed, &rec); err != nil {
+		return rec, "", errors.Wrap(err, "failed to decode the entry")
+	}
+
+	return rec, hash, nil
+}
+
+// Enabled impls zapcore.Core.
+func (c *auditCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+// With impls zapcore.Core.
+func (c *auditCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	return &auditCore{enc: enc, chain: c.chain, sink: c.sink}
+}
+
+// Check impls zapcore.Core.
+func (c *auditCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(e, c)
+}
+
+// Write impls zapcore.Core.
+// the entry is signed with the hash of the previous one, written, and synced to the disk, in order.
+func (c *auditCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	chain := c.chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+
+	all := make([]zapcore.Field, 0, len(fields)+2)
+	all = append(all, fields...)
+	all = append(all, zap.Uint64(AuditSeqKey, chain.seq+1), zap.String(AuditPrevHashKey, chain.prev))
+
+	buf, err := c.enc.EncodeEntry(e, all)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the audit entry")
+	}
+	defer buf.Free()
+
+	signed := bytes.TrimRight(buf.Bytes(), "\n")
+	if !bytes.HasSuffix(signed, []byte("}")) {
+		return errors.New("the audit entry isn't a JSON object")
+	}
+
+	if chain.sink == nil {
+		return errors.New("the audit log is closed")
+	}
+
+	hash := chain.sign(signed)
+	line := appendAuditHash(signed[:len(signed)-1], hash)
+	if _, err := chain.sink.Write(line); err != nil {
+		return errors.Wrap(err, "failed to write the audit entry")
+	}
+
+	if err := syncFile(chain.path); err != nil {
+		return errors.Wrap(err, "failed to sync the audit log")
+	}
+
+	chain.seq++
+	chain.prev = hash
+	return nil
+}
+
+// Sync impls zapcore.Core, every entry is synced once written.
+func (c *auditCore) Sync() error {
+	return nil
+}
+
+// Close closes the audit log opened by the core, which the chain stops writing to unless reopened by a later Configure.
+func (c *auditCore) Close() error {
+	chain := c.chain
+	chain.mu.Lock()
+	if chain.sink == c.sink {
+		chain.sink = nil
+	}
+	chain.mu.Unlock()
+
+	return c.sink.Close()
+}
+
+// appendAuditHash returns the JSON object without the closing brace, followed by the hash field and the line ending.
+func appendAuditHash(object []byte, hash string) []byte {
+	line := make([]byte, 0, len(object)+len(auditHashPrefix)+len(hash)+3)
+	line = append(line, object...)
+	line = append(line, auditHashPrefix...)
+	line = append(line, hash...)
+	return append(line, "\"}\n"...)
+}
+
+// syncFile commits the data written to the file to the disk,
+// since the writer of the rotated file doesn't expose its file.
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return f.Sync()
+}
+
+// truncateTorn truncates the last line of the file, which lacks the line ending, within the last max bytes,
+// and returns the number of the truncated bytes.
+func truncateTorn(path string, max int64) (int64, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to open the audit log")
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to stat the audit log")
+	}
+
+	offset := fi.Size() - max
+	if offset < 0 {
+		offset = 0
+	}
+
+	data := make([]byte, fi.Size()-offset)
+	if _, err := f.ReadAt(data, offset); err != nil && !errors.Is(err, io.EOF) {
+		return 0, errors.Wrap(err, "failed to read the audit log")
+	}
+
+	if len(data) == 0 || data[len(data)-1] == '\n' {
+		return 0, nil
+	}
+
+	i := bytes.LastIndexByte(data, '\n')
+	if i < 0 && offset > 0 {
+		return 0, errors.New("the torn last entry of the audit log is too large")
+	}
+
+	size := offset + int64(i) + 1
+	if err := f.Truncate(size); err != nil {
+		return 0, errors.Wrap(err, "failed to truncate the torn last entry of the audit log")
+	}
+
+	return fi.Size() - size, f.Sync()
+}
+
+// auditBackups returns the rotated files of the audit log from the oldest, which are named by the time of the rotation,
+// e.g. audit-2022-09-01T08-00-00.000.log of audit.log.
+func auditBackups(path string) ([]string, error) {
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext) + "-"
+
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the rotated audit log files")
+	}
+
+	var backups []string
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() || len(n) < len(prefix)+len(ext) || !strings.HasPrefix(n, prefix) || !strings.HasSuffix(n, ext) {
+			continue
+		}
+
+		if _, err := time.Parse(auditBackupTimeLayout, n[len(prefix):len(n)-len(ext)]); err == nil {
+			backups = append(backups, filepath.Join(dir, n))
+		}
+	}
+
+	// the layout of the time is ordered lexically.
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// lastLine returns the last non-empty line of the file within the last max bytes, or nil if the file doesn't exist.
+func lastLine(path string, max int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the audit log")
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to stat the audit log")
+	}
+
+	offset := fi.Size() - max
+	if offset < 0 {
+		offset = 0
+	}
+
+	data := make([]byte, fi.Size()-offset)
+	if _, err := f.ReadAt(data, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.Wrap(err, "failed to read the audit log")
+	}
+
+	data = bytes.TrimRight(data, "\n")
+	if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+		data = data[i+1:]
+	} else if offset > 0 {
+		return nil, errors.New("the last entry of the audit log is too large")
+	}
+
+	return data, nil
+}
+
+// VerifyAuditLog verifies the hash chain of the audit log files with the key from the start of the chain,
+// and returns the checkpoint of the last entry, see the VerifyAuditLogFrom.
+func VerifyAuditLog(key []byte, paths ...string) (AuditCheckpoint, error) {
+	return VerifyAuditLogFrom(key, AuditCheckpoint{}, paths...)
+}
+
+// VerifyAuditLogFrom verifies the hash chain of the audit log files with the key, which continues the checkpoint,
+// e.g. the last one verified before the rotated files holding it were removed, and reports the first entry
+// which is modified, missing, or out of order, by its file and line number.
+// the files are in the order of the chain, i.e. the rotated ones from the oldest, followed by the current one.
+// the checkpoint of the last entry is returned, the entries removed from the end of the chain are detected
+// only by comparing it with a later checkpoint kept elsewhere, e.g. the one of the previous verification.
+func VerifyAuditLogFrom(key []byte, from AuditCheckpoint, paths ...string) (AuditCheckpoint, error) {
+	if len(paths) == 0 {
+		return from, errors.New("no audit log files")
+	}
+
+	chain := &auditChain{key: key, seq: from.Seq, prev: from.Hash}
+	for _, path := range paths {
+		if err := chain.verify(path); err != nil {
+			return AuditCheckpoint{Seq: chain.seq, Hash: chain.prev}, errors.Wrapf(err, "audit log %q", path)
+		}
+	}
+
+	return AuditCheckpoint{Seq: chain.seq, Hash: chain.prev}, nil
+}
+
+// verify verifies that the entries of the audit log file continue the chain, and advances the chain.
+func (c *auditChain) verify(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the audit log")
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxAuditEntrySize)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		rec, hash, err := c.parse(line)
+		if err != nil {
+			return errors.Wrapf(err, "line %d", n)
+		}
+
+		switch {
+		case rec.Seq != c.seq+1:
+			return errors.Errorf("line %d: the sequence number %d follows %d, the entries are missing or out of order",
+				n, rec.Seq, c.seq)
+		case rec.Prev != c.prev:
+			return errors.Errorf("line %d: the entry isn't chained to the previous one", n)
+		}
+
+		c.seq, c.prev = rec.Seq, hash
+	}
+
+	return errors.Wrap(scanner.Err(), "failed to read the audit log")
+}
+
+// newAuditRoutingCore returns the core writing the entries of the audit scope to the audit core.
+func newAuditRoutingCore(core, audit zapcore.Core) zapcore.Core {
+	return &auditRoutingCore{Core: core, audit: audit}
+}
+
+// With impls zapcore.Core.
+func (c *auditRoutingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &auditRoutingCore{Core: c.Core.With(fields), audit: c.audit.With(fields)}
+}
+
+// Check impls zapcore.Core.
+func (c *auditRoutingCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if e.LoggerName == AuditScopeName {
+		return c.audit.Check(e, ce)
+	}
+
+	return c.Core.Check(e, ce)
+}
+
+// Sync impls zapcore.Core.
+func (c *auditRoutingCore) Sync() error {
+	return errors.CombineErrors(c.Core.Sync(), c.audit.Sync())
+}
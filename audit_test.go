@@ -0,0 +1,259 @@
+package lager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var testAuditKey = []byte("test-audit-key")
+
+// configureAudit configures the audit log at the path, with the main output at the other path.
+func configureAudit(t *testing.T, auditPath, outPath string) {
+	o := DefaultOptions().WithAudit(AuditConfig{Path: auditPath, Key: testAuditKey}).WithSampling("1/0")
+	o.OutputPaths = []string{outPath}
+	o.DropReportInterval = time.Hour
+	require.NoError(t, Configure(o))
+	t.Cleanup(func() { _ = Configure(DefaultOptions()) })
+}
+
+func TestAuditLog(t *testing.T) {
+	dir := t.TempDir()
+	auditPath, outPath := filepath.Join(dir, "audit.log"), filepath.Join(dir, "out.log")
+	
+	configureAudit(t, auditPath, outPath)
+	for i := 0; i < 3; i++ {
+		AuditScope().Debug("user deleted", zap.Int("i", i))
+	}
+	zap.L().Info("not audited")
+	zap.L().Info("not audited")
+	prev := zap.L().Named(AuditScopeName)
+	
+	// the chain is continued by the next Configure, and by the loggers of the previous one.
+	configureAudit(t, auditPath, outPath)
+	zap.L().Named(AuditScopeName).With(zap.String("actor", "admin")).Info("user created")
+	prev.Info("user updated")
+	require.NoError(t, Configure(DefaultOptions()))
+	
+	checkpoint, err := VerifyAuditLog(testAuditKey, auditPath)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), checkpoint.Seq)
+	_, err = VerifyAuditLog([]byte("other-key"), auditPath)
+	assert.Error(t, err)
+	
+	audit := readLines(t, auditPath)
+	require.Len(t, audit, 5, "expected the audit entries never sampled")
+	for i, entry := range audit {
+		assert.Equal(t, AuditScopeName, entry["scope"])
+		assert.Equal(t, float64(i+1), entry[AuditSeqKey])
+		if i > 0 {
+			assert.Equal(t, audit[i-1][AuditHashKey], entry[AuditPrevHashKey])
+		}
+	}
+	assert.Equal(t, "", audit[0][AuditPrevHashKey])
+	assert.Equal(t, "admin", audit[3]["actor"])
+	assert.Equal(t, "user updated", audit[4]["msg"])
+	assert.Equal(t, audit[4][AuditHashKey], checkpoint.Hash)
+	
+	out, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(out), "not audited"), "expected the main output sampled")
+	assert.NotContains(t, string(out), AuditScopeName, "expected the audit entries out of the main output")
+}
+
+func TestVerifyAuditLogTampered(t *testing.T) {
+	dir := t.TempDir()
+	auditPath := filepath.Join(dir, "audit.log")
+	
+	configureAudit(t, auditPath, filepath.Join(dir, "out.log"))
+	for i := 0; i < 3; i++ {
+		AuditScope().Info("granted", zap.String("user", "alice"))
+	}
+	prev := readLines(t, auditPath)[0]
+	require.NoError(t, Configure(DefaultOptions()))
+	
+	data, err := os.ReadFile(auditPath)
+	require.NoError(t, err)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	
+	tests := map[string]struct {
+		data []byte
+		from AuditCheckpoint
+		err  string
+	}{
+		"modified": {
+			data: bytes.Replace(data, []byte("alice"), []byte("mallory"), 1),
+			err:  "line 1: the hash of the entry mismatches",
+		},
+		"deleted": {
+			data: bytes.Join([][]byte{lines[0], lines[2]}, nil),
+			err:  "line 2: the sequence number 3 follows 1",
+		},
+		"reordered": {
+			data: bytes.Join([][]byte{lines[0], lines[2], lines[1]}, nil),
+			err:  "line 2: the sequence number 3 follows 1",
+		},
+		"head deleted": {
+			data: bytes.Join([][]byte{lines[1], lines[2]}, nil),
+			err:  "line 1: the sequence number 2 follows 0",
+		},
+		"rotated": {
+			data: bytes.Join([][]byte{lines[1], lines[2]}, nil),
+			from: AuditCheckpoint{Seq: 1, Hash: prev[AuditHashKey].(string)},
+		},
+		"rotated unchained": {
+			data: bytes.Join([][]byte{lines[1], lines[2]}, nil),
+			from: AuditCheckpoint{Seq: 1, Hash: "00"},
+			err:  "line 1: the entry isn't chained to the previous one",
+		},
+	}
+	
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name+".log")
+			require.NoError(t, os.WriteFile(path, tt.data, 0o600))
+			
+			_, err := VerifyAuditLogFrom(testAuditKey, tt.from, path)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestVerifyAuditLogRotated(t *testing.T) {
+	dir := t.TempDir()
+	auditPath := filepath.Join(dir, "audit.log")
+	
+	configureAudit(t, auditPath, filepath.Join(dir, "out.log"))
+	for i := 0; i < 3; i++ {
+		AuditScope().Info("granted", zap.Int("i", i))
+	}
+	require.NoError(t, Configure(DefaultOptions()))
+	
+	data, err := os.ReadFile(auditPath)
+	require.NoError(t, err)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	backup, current := filepath.Join(dir, "audit-backup.log"), filepath.Join(dir, "audit-current.log")
+	require.NoError(t, os.WriteFile(backup, lines[0], 0o600))
+	require.NoError(t, os.WriteFile(current, bytes.Join(lines[1:], nil), 0o600))
+	
+	checkpoint, err := VerifyAuditLog(testAuditKey, backup, current)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), checkpoint.Seq)
+	
+	_, err = VerifyAuditLog(testAuditKey, current, backup)
+	require.Error(t, err, "expected the files out of order detected")
+	assert.Contains(t, err.Error(), "the sequence number 2 follows 0")
+	
+	_, err = VerifyAuditLog(testAuditKey)
+	assert.Error(t, err, "expected the files required")
+}
+
+func TestAuditLogRetention(t *testing.T) {
+	dir := t.TempDir()
+	o := DefaultOptions()
+	
+	audit, err := newAuditCore(AuditConfig{Path: filepath.Join(dir, "kept.log"), Key: testAuditKey}, o)
+	require.NoError(t, err)
+	defer audit.Close()
+	assert.Equal(t, o.RotationMaxSize, audit.sink.MaxSize)
+	assert.Zero(t, audit.sink.MaxAge, "expected the rotated files kept by default")
+	assert.Zero(t, audit.sink.MaxBackups, "expected the rotated files kept by default")
+	
+	audit, err = newAuditCore(AuditConfig{Path: filepath.Join(dir, "removed.log"), Key: testAuditKey,
+		RotationMaxAge: 365, RotationMaxBackups: 10}, o)
+	require.NoError(t, err)
+	defer audit.Close()
+	assert.Equal(t, 365, audit.sink.MaxAge)
+	assert.Equal(t, 10, audit.sink.MaxBackups)
+}
+
+// forgetAuditChain forgets the chain of the audit log, as if the process restarted.
+func forgetAuditChain(t *testing.T, path string) {
+	abs, err := filepath.Abs(path)
+	require.NoError(t, err)
+	
+	auditChainsMu.Lock()
+	defer auditChainsMu.Unlock()
+	delete(auditChains, auditChainID{path: abs, key: string(testAuditKey)})
+}
+
+func TestAuditLogResumeTorn(t *testing.T) {
+	dir := t.TempDir()
+	auditPath := filepath.Join(dir, "audit.log")
+	
+	configureAudit(t, auditPath, filepath.Join(dir, "out.log"))
+	AuditScope().Info("granted")
+	AuditScope().Info("granted")
+	require.NoError(t, Configure(DefaultOptions()))
+	
+	// the process crashed while writing the next entry.
+	torn := `{"level":"info","msg":"gra`
+	f, err := os.OpenFile(auditPath, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(torn)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	forgetAuditChain(t, auditPath)
+	
+	configureAudit(t, auditPath, filepath.Join(dir, "out.log"))
+	AuditScope().Info("revoked")
+	require.NoError(t, Configure(DefaultOptions()))
+	
+	_, err = VerifyAuditLog(testAuditKey, auditPath)
+	require.NoError(t, err)
+	audit := readLines(t, auditPath)
+	require.Len(t, audit, 4)
+	assert.Equal(t, float64(3), audit[2][AuditSeqKey])
+	assert.Equal(t, float64(len(torn)), audit[2][auditTruncatedKey], "expected the truncation recorded")
+	assert.Equal(t, "revoked", audit[3]["msg"])
+}
+
+func TestAuditLogResumeRotated(t *testing.T) {
+	dir := t.TempDir()
+	auditPath := filepath.Join(dir, "audit.log")
+	
+	configureAudit(t, auditPath, filepath.Join(dir, "out.log"))
+	AuditScope().Info("granted")
+	AuditScope().Info("granted")
+	require.NoError(t, Configure(DefaultOptions()))
+	
+	// the process restarted right after the rotation.
+	backup := filepath.Join(dir, "audit-2022-09-01T08-00-00.000.log")
+	require.NoError(t, os.Rename(auditPath, backup))
+	require.NoError(t, os.WriteFile(auditPath, nil, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "audit-other.log"), []byte("not rotated\n"), 0o600))
+	forgetAuditChain(t, auditPath)
+	
+	configureAudit(t, auditPath, filepath.Join(dir, "out.log"))
+	AuditScope().Info("revoked")
+	require.NoError(t, Configure(DefaultOptions()))
+	
+	checkpoint, err := VerifyAuditLog(testAuditKey, backup, auditPath)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), checkpoint.Seq, "expected the chain continued from the rotated file")
+}
+
+func TestAuditLogResumeModified(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, os.WriteFile(auditPath, []byte(`{"msg":"forged","seq":7,"hash":"00"}`+"\n"), 0o600))
+	
+	o := DefaultOptions().WithAudit(AuditConfig{Path: auditPath, Key: testAuditKey})
+	err := Configure(o)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the hash of the entry mismatches")
+	assert.Contains(t, err.Error(), "moved aside to start a new chain", "expected the recovery explained")
+	
+	assert.Error(t, Configure(DefaultOptions().WithAudit(AuditConfig{Path: auditPath})), "expected the key required")
+}
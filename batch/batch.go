@@ -0,0 +1,348 @@
+// Package batch provides the asynchronous batching layer of the sinks exporting the log entries
+// to a remote service, e.g. the OTLP, Loki or Elasticsearch.
+package batch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/atomic"
+)
+
+const (
+	defaultMaxBatchSize   = 512
+	defaultMaxQueueSize   = 2048
+	defaultFlushInterval  = time.Second
+	defaultExportTimeout  = 10 * time.Second
+	defaultMaxRetries     = 3
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+	defaultDropReport     = 10 * time.Second
+)
+
+var errClosed = errors.New("the batcher is closed")
+
+type (
+	// Config the config of a Batcher, the zero values are replaced by the defaults.
+	Config struct {
+		// the maximum number of items exported at once, default 512.
+		MaxBatchSize int
+
+		// the maximum number of queued items, the items added to a full queue are dropped, default 2048.
+		MaxQueueSize int
+
+		// the interval exporting the queued items, even though the batch isn't full, default 1s.
+		FlushInterval time.Duration
+
+		// the timeout of each export attempt, default 10s.
+		ExportTimeout time.Duration
+
+		// the maximum number of retries of a failed export, default 3, a negative value disables the retry.
+		MaxRetries int
+
+		// the backoff before the first retry, which is doubled for each subsequent retry, default 100ms.
+		InitialBackoff time.Duration
+
+		// the maximum backoff between the retries, default 5s.
+		MaxBackoff time.Duration
+
+		// the interval of the OnDrop reports, default 10s.
+		DropReportInterval time.Duration
+
+		// OnDrop reports the number of the items dropped since the last report, if any, every DropReportInterval,
+		// e.g. by a "dropped N messages" summary, so that the drops are reported without flooding the error output.
+		// it's called by the background goroutine, and mustn't block.
+		OnDrop func(dropped uint64)
+	}
+
+	// ExportFunc exports a batch of items, an error marked by Permanent isn't retried.
+	ExportFunc[T any] func(ctx context.Context, items []T) error
+
+	// Batcher queues the added items, and exports them in batches by a background goroutine,
+	// once a batch is full or the flush interval elapses. a failed export is retried with an exponential backoff.
+	Batcher[T any] struct {
+		cfg    Config
+		export ExportFunc[T]
+
+		mu     sync.Mutex
+		queue  []T
+		closed bool
+
+		dropped *atomic.Uint64
+
+		full      chan struct{}
+		flushReqs chan chan error
+		done      chan struct{}
+		stopped   chan struct{}
+		closeOnce sync.Once
+	}
+
+	// permanentError an error, which isn't worth retrying.
+	permanentError struct {
+		cause error
+	}
+
+	// retryError the error of a partially failed export, whose failed items are retried alone.
+	retryError[T any] struct {
+		cause    error
+		items    []T
+		rejected error
+	}
+)
+
+// New returns a started Batcher exporting the items by the export function.
+func New[T any](cfg Config, export ExportFunc[T]) *Batcher[T] {
+	if cfg.MaxBatchSize <= 0 {
+		cfg.MaxBatchSize = defaultMaxBatchSize
+	}
+	if cfg.MaxQueueSize <= 0 {
+		cfg.MaxQueueSize = defaultMaxQueueSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	if cfg.ExportTimeout <= 0 {
+		cfg.ExportTimeout = defaultExportTimeout
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.DropReportInterval <= 0 {
+		cfg.DropReportInterval = defaultDropReport
+	}
+
+	b := &Batcher[T]{
+		cfg:       cfg,
+		export:    export,
+		dropped:   atomic.NewUint64(0),
+		full:      make(chan struct{}, 1),
+		flushReqs: make(chan chan error),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go b.run()
+
+	return b
+}
+
+// Permanent marks the error as permanent, so that the failed export isn't retried,
+// e.g. the remote service rejects the request as malformed.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &permanentError{cause: err}
+}
+
+// IsPermanent returns whether the error is marked by Permanent.
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// Retry returns the error of a partially failed export, where only the items failed by the err are retried,
+// and the others are either exported, or rejected by the rejected error, which isn't retried and may be nil,
+// e.g. a bulk API throttling some items, and rejecting some others as malformed.
+// nothing is retried if the err is nil, and the rejected error is returned as-is.
+func Retry[T any](items []T, err, rejected error) error {
+	if err == nil {
+		return rejected
+	}
+
+	return &retryError[T]{cause: err, items: items, rejected: rejected}
+}
+
+// Add queues the item, it returns false when the item is dropped, since the queue is full or the batcher is closed.
+func (b *Batcher[T]) Add(item T) bool {
+	b.mu.Lock()
+	if b.closed || len(b.queue) >= b.cfg.MaxQueueSize {
+		b.mu.Unlock()
+		b.dropped.Inc()
+		return false
+	}
+
+	b.queue = append(b.queue, item)
+	full := len(b.queue) >= b.cfg.MaxBatchSize
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+
+	return true
+}
+
+// Dropped returns the number of the dropped items.
+func (b *Batcher[T]) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Flush exports all the queued items, and waits for the exports to complete or the context to be done.
+func (b *Batcher[T]) Flush(ctx context.Context) error {
+	reply := make(chan error, 1)
+	select {
+	case b.flushReqs <- reply:
+	case <-b.stopped:
+		return errClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting the items, exports the queued ones,
+// and waits for the background goroutine to stop or the context to be done.
+func (b *Batcher[T]) Close(ctx context.Context) error {
+	b.closeOnce.Do(func() {
+		b.mu.Lock()
+		b.closed = true
+		b.mu.Unlock()
+
+		close(b.done)
+	})
+
+	select {
+	case <-b.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run exports the queued items until the batcher is closed.
+func (b *Batcher[T]) run() {
+	defer close(b.stopped)
+
+	ticker := time.NewTicker(b.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	dropTicker := time.NewTicker(b.cfg.DropReportInterval)
+	defer dropTicker.Stop()
+
+	var reported uint64
+	reportDrops := func() {
+		if n := b.dropped.Load(); n > reported && b.cfg.OnDrop != nil {
+			b.cfg.OnDrop(n - reported)
+			reported = n
+		}
+	}
+	defer reportDrops()
+
+	for {
+		select {
+		case <-dropTicker.C:
+			reportDrops()
+		case <-ticker.C:
+			_ = b.exportQueued()
+		case <-b.full:
+			_ = b.exportQueued()
+		case reply := <-b.flushReqs:
+			reply <- b.exportQueued()
+		case <-b.done:
+			_ = b.exportQueued()
+			return
+		}
+	}
+}
+
+// exportQueued exports the queued items batch by batch.
+func (b *Batcher[T]) exportQueued() error {
+	var err error
+	for {
+		b.mu.Lock()
+		n := len(b.queue)
+		if n > b.cfg.MaxBatchSize {
+			n = b.cfg.MaxBatchSize
+		}
+		items := b.queue[:n:n]
+		b.queue = b.queue[n:]
+		b.mu.Unlock()
+
+		if len(items) == 0 {
+			return err
+		}
+
+		err = errors.CombineErrors(err, b.exportWithRetry(items))
+	}
+}
+
+// exportWithRetry exports the items, a failed export is retried with an exponential backoff,
+// unless the error is permanent or the batcher is closed, and only the failed items of a partially failed one.
+func (b *Batcher[T]) exportWithRetry(items []T) error {
+	var rejected error
+
+	backoff := b.cfg.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), b.cfg.ExportTimeout)
+		err := b.export(ctx, items)
+		cancel()
+
+		var re *retryError[T]
+		if errors.As(err, &re) {
+			rejected = errors.CombineErrors(rejected, re.rejected)
+			items, err = re.items, re.cause
+			if len(items) == 0 {
+				err = nil
+			}
+		}
+
+		if err == nil || IsPermanent(err) || attempt >= b.cfg.MaxRetries {
+			return errors.CombineErrors(rejected, err)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-b.done:
+			// one more attempt for the closing batcher without waiting.
+			if attempt+1 < b.cfg.MaxRetries {
+				attempt = b.cfg.MaxRetries - 1
+			}
+		}
+
+		if backoff *= 2; backoff > b.cfg.MaxBackoff {
+			backoff = b.cfg.MaxBackoff
+		}
+	}
+}
+
+// Error impls error.
+func (e *permanentError) Error() string {
+	return e.cause.Error()
+}
+
+// Unwrap returns the cause of the permanent error.
+func (e *permanentError) Unwrap() error {
+	return e.cause
+}
+
+// Error impls error.
+func (e *retryError[T]) Error() string {
+	if e.rejected == nil {
+		return e.cause.Error()
+	}
+
+	return e.cause.Error() + "; " + e.rejected.Error()
+}
+
+// Unwrap returns the cause of the retry error.
+func (e *retryError[T]) Unwrap() error {
+	return e.cause
+}
@@ -0,0 +1,164 @@
+package batch
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	mu       sync.Mutex
+	batches  [][]int
+	failures int
+	err      error
+}
+
+func (r *recorder) export(_ context.Context, items []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	if r.failures > 0 {
+		r.failures--
+		return r.err
+	}
+	
+	r.batches = append(r.batches, append([]int(nil), items...))
+	return nil
+}
+
+func (r *recorder) snapshot() [][]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	return append([][]int(nil), r.batches...)
+}
+
+func TestBatcherBatchSize(t *testing.T) {
+	r := &recorder{}
+	b := New(Config{MaxBatchSize: 2, FlushInterval: time.Hour}, r.export)
+	defer b.Close(context.Background())
+	
+	for i := 0; i < 5; i++ {
+		assert.True(t, b.Add(i))
+	}
+	
+	require.NoError(t, b.Flush(context.Background()))
+	assert.Equal(t, [][]int{{0, 1}, {2, 3}, {4}}, r.snapshot())
+}
+
+func TestBatcherFlushInterval(t *testing.T) {
+	r := &recorder{}
+	b := New(Config{FlushInterval: 10 * time.Millisecond}, r.export)
+	defer b.Close(context.Background())
+	
+	b.Add(1)
+	assert.Eventually(t, func() bool { return len(r.snapshot()) == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestBatcherRetry(t *testing.T) {
+	r := &recorder{failures: 2, err: errors.New("unavailable")}
+	b := New(Config{FlushInterval: time.Hour, InitialBackoff: time.Millisecond}, r.export)
+	defer b.Close(context.Background())
+	
+	b.Add(1)
+	require.NoError(t, b.Flush(context.Background()))
+	assert.Equal(t, [][]int{{1}}, r.snapshot())
+	
+	r.failures, r.err = 10, errors.New("unavailable")
+	b.Add(2)
+	assert.ErrorContains(t, b.Flush(context.Background()), "unavailable")
+	assert.Equal(t, 6, r.failures, "expected the first attempt and 3 retries")
+}
+
+func TestBatcherPermanentError(t *testing.T) {
+	r := &recorder{failures: 2, err: Permanent(errors.New("bad request"))}
+	b := New(Config{FlushInterval: time.Hour, InitialBackoff: time.Millisecond}, r.export)
+	defer b.Close(context.Background())
+	
+	b.Add(1)
+	err := b.Flush(context.Background())
+	assert.True(t, IsPermanent(err))
+	assert.ErrorContains(t, err, "bad request")
+	assert.Equal(t, 1, r.failures, "expected no retry")
+	
+	assert.Nil(t, Permanent(nil))
+}
+
+func TestBatcherRetryItems(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]int
+	)
+	b := New(Config{FlushInterval: time.Hour, InitialBackoff: time.Millisecond}, func(_ context.Context, items []int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		
+		batches = append(batches, append([]int(nil), items...))
+		if len(batches) == 1 {
+			return Retry([]int{2}, errors.New("throttled"), Permanent(errors.New("malformed")))
+		}
+		return nil
+	})
+	defer b.Close(context.Background())
+	
+	b.Add(1)
+	b.Add(2)
+	b.Add(3)
+	err := b.Flush(context.Background())
+	assert.True(t, IsPermanent(err))
+	assert.ErrorContains(t, err, "malformed")
+	assert.NotContains(t, err.Error(), "throttled")
+	
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, [][]int{{1, 2, 3}, {2}}, batches, "expected only the failed items retried")
+}
+
+func TestRetryWithoutCause(t *testing.T) {
+	assert.NoError(t, Retry([]int{1}, nil, nil))
+	
+	rejected := Permanent(errors.New("malformed"))
+	err := Retry([]int{1}, nil, rejected)
+	assert.Equal(t, rejected, err, "expected the rejected error only")
+	assert.Equal(t, "malformed", err.Error())
+}
+
+func TestBatcherDropReport(t *testing.T) {
+	reports := make(chan uint64, 10)
+	b := New(Config{MaxQueueSize: 1, FlushInterval: time.Hour, DropReportInterval: 10 * time.Millisecond,
+		OnDrop: func(n uint64) { reports <- n }}, (&recorder{}).export)
+	
+	b.Add(1)
+	for i := 0; i < 3; i++ {
+		assert.False(t, b.Add(i))
+	}
+	assert.Equal(t, uint64(3), <-reports, "expected the drops reported at once")
+	
+	assert.False(t, b.Add(4))
+	require.NoError(t, b.Close(context.Background()))
+	assert.Equal(t, uint64(1), <-reports, "expected the drops since the last report reported")
+	assert.Empty(t, reports)
+}
+
+func TestBatcherDrop(t *testing.T) {
+	r := &recorder{}
+	b := New(Config{MaxQueueSize: 2, MaxBatchSize: 10, FlushInterval: time.Hour}, r.export)
+	
+	assert.True(t, b.Add(1))
+	assert.True(t, b.Add(2))
+	assert.False(t, b.Add(3))
+	assert.Equal(t, uint64(1), b.Dropped())
+	
+	require.NoError(t, b.Close(context.Background()))
+	assert.Equal(t, [][]int{{1, 2}}, r.snapshot(), "expected the queued items to be exported on close")
+	
+	assert.False(t, b.Add(4))
+	assert.Equal(t, uint64(2), b.Dropped())
+	assert.Error(t, b.Flush(context.Background()))
+	assert.NoError(t, b.Close(context.Background()))
+}
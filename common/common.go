@@ -0,0 +1,35 @@
+// Package common holds the constants, types and helpers shared by the lager packages and its experimental sinks.
+//
+// it depends on nothing of lager, so that both lager and the sinks plugged into it can import it.
+package common
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// placeholder names for the log schema.
+	
+	LogPlaceholderLoggerName = "@logger"
+	LogPlaceholderMessage    = "@message"
+	LogPlaceholderInstance   = "@instance"
+	LogPlaceholderVer        = "@ver"
+	LogPlaceholderAppID      = "@app_id"
+	
+	// the keys of the trace correlation fields, following the OpenTelemetry log data model,
+	// the ids are hex encoded, and the flags are the two hex digits of the W3C trace flags.
+	
+	TraceIDKey    = "trace_id"
+	SpanIDKey     = "span_id"
+	TraceFlagsKey = "trace_flags"
+)
+
+type (
+	// CloseFunc closes the resources held by a sink.
+	CloseFunc func() error
+	
+	// Extension plugs a sink into the configured core.
+	// it returns the core replacing the provided one, typically a tee of both,
+	// and the CloseFunc releasing the resources of the sink.
+	Extension func(core zapcore.Core) (zapcore.Core, CloseFunc, error)
+)
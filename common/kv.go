@@ -0,0 +1,43 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// MissingValue the value of a key without value of the structured logging.
+const MissingValue = "(MISSING)"
+
+// KVToFields returns the fields of the alternating keys and values of the klog and logr structured logging,
+// preceded by the error if not nil, a non-string key is formatted by the fmt.Sprint,
+// and the value of a missing one is the MissingValue.
+func KVToFields(err error, keysAndValues []interface{}) []zapcore.Field {
+	fields := make([]zapcore.Field, 0, len(keysAndValues)/2+1)
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		
+		var value interface{} = MissingValue
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		
+		fields = append(fields, zap.Any(key, value))
+	}
+	
+	return fields
+}
+
+// Sprintln formats the args like the fmt.Sprintln without the trailing newline.
+func Sprintln(args ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
@@ -1,5 +1,9 @@
 package lager
 
+import (
+	"github.com/dapings/lager/common"
+)
+
 const (
 	// cstRFC3339Nano = "2006-01-02 15:04:05.999999999Z08:00" // only placeholder.
 	
@@ -7,11 +11,11 @@ const (
 	defaultConsoleSeparator = " | "
 	
 	// placeholder names for the log schema.
-	logPlaceholderLoggerName = "@logger"
-	logPlaceholderMessage    = "@message"
-	logPlaceholderInstance   = "@instance"
-	logPlaceholderVer        = "@ver"
-	logPlaceholderAppID      = "@app_id"
+	logPlaceholderLoggerName = common.LogPlaceholderLoggerName
+	logPlaceholderMessage    = common.LogPlaceholderMessage
+	logPlaceholderInstance   = common.LogPlaceholderInstance
+	logPlaceholderVer        = common.LogPlaceholderVer
+	logPlaceholderAppID      = common.LogPlaceholderAppID
 	
 	// PlaceholderOutputLevelEnvName the placeholder env names for the log schema.
 	PlaceholderOutputLevelEnvName = "LOG_OUTPUT_LEVEL"
@@ -0,0 +1,286 @@
+package lager
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	uatomic "go.uber.org/atomic"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	// configMu guards the closeSinks.
+	configMu sync.Mutex
+	// closeSinks closes the sinks opened by the latest Configure.
+	closeSinks CloseFunc
+)
+
+// Configure initializes the global zap logger and the registered scopes based on the options,
+// the sinks opened by a previous call are closed once the new logger takes effect.
+//
+// a scope takes its levels, caller annotation, sampling and rate limit from the options,
+// or those of the default scope if unspecified, and the global zap logger is gated by the levels
+// and limited by the sampling and rate limit of the default scope.
+// the AuditScope logs all levels and is never limited once the audit log of the WithAudit is configured.
+func Configure(options *Options) error {
+	core, errSink, closeFunc, err := prepZap(options)
+	if err != nil {
+		return err
+	}
+
+	if err := applyScopes(options); err != nil {
+		_ = closeFunc()
+		return err
+	}
+
+	b := &zapBase{core: core, errSink: errSink}
+	base.Store(b)
+
+	zapOpts := []zap.Option{zap.ErrorOutput(errSink), zap.AddStacktrace(zapEnabler(defaultScope.stackTraceLevel))}
+	if defaultScope.GetLogCallers() {
+		zapOpts = append(zapOpts, zap.AddCaller())
+	}
+
+	// the global zap logger shares the limits of the default scope.
+	if limits := defaultScope.limits.Load(); limits != nil {
+		core = limits.wrap(b)
+	}
+
+	zap.ReplaceGlobals(zap.New(newLevelCore(core, zapEnabler(defaultScope.outputLevel)), zapOpts...))
+	spanEventLevel.Store(int32(options.spanEventLevel))
+	unsampledMatchers := unsampledRouteMatchers(options)
+	unsampledRoutes.Store(&unsampledMatchers)
+	configureGrpc(options)
+
+	dropReportInterval := time.Duration(0)
+	if options.samplings != "" || options.rateLimits != "" {
+		dropReportInterval = options.DropReportInterval
+		if dropReportInterval <= 0 {
+			dropReportInterval = defaultDropReportInterval
+		}
+	}
+	startDropReports(dropReportInterval)
+
+	configMu.Lock()
+	prevCloseSinks := closeSinks
+	closeSinks = closeFunc
+	configMu.Unlock()
+
+	if prevCloseSinks != nil {
+		return prevCloseSinks()
+	}
+
+	return nil
+}
+
+// applyScopes sets the levels and the caller annotation of the registered scopes by the options.
+func applyScopes(options *Options) error {
+	defaultOutputLevel, err := options.GetOutputLevel(DefaultScopeName)
+	if err != nil {
+		return err
+	}
+
+	defaultStackTraceLevel, err := options.GetStackTraceLevel(DefaultScopeName)
+	if err != nil {
+		return err
+	}
+
+	type scopeConfig struct {
+		outputLevel, stackTraceLevel Level
+		logCallers                   bool
+		limits                       limitConfig
+	}
+
+	all := sortedScopes()
+	configs := make([]scopeConfig, len(all))
+	for i, s := range all {
+		if configs[i].outputLevel, err = getLevel(options.outputLevels, s.name, defaultOutputLevel); err != nil {
+			return err
+		}
+		if configs[i].stackTraceLevel, err = getLevel(options.stackTraceLevels, s.name, defaultStackTraceLevel); err != nil {
+			return err
+		}
+		configs[i].logCallers = options.GetLogCallers(s.name) || options.GetLogCallers(DefaultScopeName)
+		if configs[i].limits, err = options.limitConfig(s.name); err != nil {
+			return err
+		}
+
+		// the audit entries are never dropped.
+		if s == auditScope && options.audit != nil {
+			configs[i].outputLevel, configs[i].limits = DebugLevel, limitConfig{}
+		}
+	}
+
+	for i, s := range all {
+		s.SetOutputLevel(configs[i].outputLevel)
+		s.SetStackTraceLevel(configs[i].stackTraceLevel)
+		s.SetLogCallers(configs[i].logCallers)
+
+		// the drops not reported yet are counted on.
+		var dropped *uatomic.Int64
+		if prev := s.limits.Load(); prev != nil {
+			dropped = prev.dropped
+		}
+		s.limits.Store(newScopeLimits(configs[i].limits, dropped))
+	}
+
+	return nil
+}
+
+// prepZap builds the core and the error output sink based on the options,
+// the core enables all levels, which are gated by the scopes.
+func prepZap(options *Options) (zapcore.Core, zapcore.WriteSyncer, CloseFunc, error) {
+	enc, err := newEncoder(options)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	sink, entrySinks, closeOut, err := openSinks(options.OutputPaths...)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "failed to open the output paths")
+	}
+
+	errSink, errEntrySinks, closeErrOut, err := openSinks(options.ErrOutputPaths...)
+	if err != nil {
+		_ = closeOut()
+		return nil, nil, nil, errors.Wrap(err, "failed to open the error output paths")
+	}
+
+	closers := []CloseFunc{closeOut, closeErrOut}
+
+	for _, open := range options.outputSinks {
+		s, err := open()
+		if err != nil {
+			_ = closeAll(closers)
+			return nil, nil, nil, errors.Wrap(err, "failed to open the output sink")
+		}
+
+		closers = append(closers, s.Close)
+		if es, ok := s.(EntrySink); ok {
+			entrySinks = append(entrySinks, es)
+		} else {
+			sink = zapcore.NewMultiWriteSyncer(sink, s)
+		}
+	}
+
+	writers := []zapcore.WriteSyncer{sink}
+	if options.RotateOutputPath != "" {
+		rotate := newRotateSink(options.RotateOutputPath,
+			options.RotationMaxSize, options.RotationMaxAge, options.RotationMaxBackups)
+		closers = append(closers, rotate.Close)
+		writers = append(writers, rotate)
+	}
+
+	for _, w := range options.SpecificWriters {
+		writers = append(writers, zapcore.AddSync(w))
+	}
+
+	if len(writers) > 1 {
+		sink = zapcore.NewMultiWriteSyncer(writers...)
+	}
+
+	var core zapcore.Core = zapcore.NewCore(enc, sink, zapcore.DebugLevel)
+	if len(entrySinks) > 0 || len(options.messageSinks) > 0 {
+		cores := []zapcore.Core{core}
+		for _, es := range entrySinks {
+			cores = append(cores, newEntrySinkCore(zapcore.DebugLevel, es))
+		}
+		for _, ms := range options.messageSinks {
+			cores = append(cores, NewMessageSinkCore(enc.Clone(), ms.sink, zapcore.DebugLevel, ms.keyField))
+		}
+		core = zapcore.NewTee(cores...)
+	}
+
+	core, routeClosers, err := routeOutputs(core, enc, errSink, errEntrySinks, options)
+	if err != nil {
+		_ = closeAll(closers)
+		return nil, nil, nil, err
+	}
+	closers = append(closers, routeClosers...)
+
+	if options.appID != undefinedAppID {
+		core = core.With([]zapcore.Field{zap.String(logPlaceholderAppID, options.appID)})
+	}
+
+	for _, ext := range options.extensions {
+		extCore, closeExt, err := ext(core)
+		if err != nil {
+			_ = closeAll(closers)
+			return nil, nil, nil, errors.Wrap(err, "failed to apply the extension")
+		}
+
+		core = extCore
+		if closeExt != nil {
+			closers = append(closers, closeExt)
+		}
+	}
+
+	if len(options.redactions) > 0 {
+		core = NewRedactionCore(core, options.redactions)
+	}
+
+	if options.dedup {
+		dedup := newDedupCore(core, options.dedupWindow, errSink)
+		core = dedup
+		// the pending duplicates are written before the sinks are closed.
+		closers = append(closers, dedup.Close)
+	}
+
+	if options.audit != nil {
+		audit, err := newAuditCore(*options.audit, options)
+		if err != nil {
+			_ = closeAll(closers)
+			return nil, nil, nil, errors.Wrap(err, "failed to open the audit log")
+		}
+
+		core = newAuditRoutingCore(core, audit)
+		closers = append(closers, audit.Close)
+	}
+
+	return core, errSink, func() error { return closeAll(closers) }, nil
+}
+
+// closeAll calls the closers in the reverse order, and combines their errors.
+func closeAll(closers []CloseFunc) error {
+	var err error
+	for i := len(closers) - 1; i >= 0; i-- {
+		err = errors.CombineErrors(err, closers[i]())
+	}
+
+	return err
+}
+
+// newEncoder returns the encoder matching the format of the options.
+func newEncoder(options *Options) (zapcore.Encoder, error) {
+	switch {
+	case options.useStackdriverFormat:
+		return NewStackdriverProjectEncoder(StackdriverEncoderConfig(), options.stackdriverTargetProject), nil
+	case options.XMLEncoding:
+		return nil, errors.New("the XML encoding is not supported yet")
+	case options.JSONEncoding:
+		return zapcore.NewJSONEncoder(defaultEncoderConfig()), nil
+	default:
+		encCfg := defaultEncoderConfig()
+		encCfg.ConsoleSeparator = defaultConsoleSeparator
+		return zapcore.NewConsoleEncoder(encCfg), nil
+	}
+}
+
+// defaultEncoderConfig returns the encoder config of the console and JSON encoding.
+func defaultEncoderConfig() zapcore.EncoderConfig {
+	return zapcore.EncoderConfig{
+		TimeKey:        "time",
+		LevelKey:       "level",
+		NameKey:        "scope",
+		CallerKey:      "caller",
+		MessageKey:     "msg",
+		StacktraceKey:  "stack",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+}
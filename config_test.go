@@ -0,0 +1,82 @@
+package lager
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestConfigureStackdriverFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	o := DefaultOptions().WithStackdriverLoggingFormat()
+	o.OutputPaths = []string{path}
+	o.SetLogCallers(DefaultScopeName, true)
+	require.NoError(t, Configure(o))
+	
+	zap.L().Named("ads").Info("hello")
+	zap.L().Debug("not enabled")
+	_ = zap.L().Sync()
+	
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &got))
+	assert.Equal(t, "INFO", got[StackdriverSeverityKey])
+	assert.Equal(t, "hello", got[StackdriverMessageKey])
+	assert.Equal(t, map[string]interface{}{"scope": "ads"}, got[StackdriverLabelsKey])
+	assert.Contains(t, got[StackdriverSourceLocationKey], "file")
+	
+	require.NoError(t, Configure(DefaultOptions()))
+}
+
+func TestConfigureErrors(t *testing.T) {
+	o := DefaultOptions()
+	o.XMLEncoding = true
+	assert.Error(t, Configure(o))
+	
+	o = DefaultOptions()
+	o.outputLevels = "@default:foo"
+	assert.Error(t, Configure(o))
+	
+	o = DefaultOptions()
+	o.OutputPaths = []string{"/nonexistent/dir/out.log"}
+	assert.Error(t, Configure(o))
+}
+
+func TestConfigureExtensions(t *testing.T) {
+	var applied, closed []string
+	ext := func(name string, err error) Extension {
+		return func(core zapcore.Core) (zapcore.Core, CloseFunc, error) {
+			if err != nil {
+				return nil, nil, err
+			}
+			
+			applied = append(applied, name)
+			return core.With([]zapcore.Field{zap.Bool(name, true)}), func() error {
+				closed = append(closed, name)
+				return nil
+			}, nil
+		}
+	}
+	
+	o := DefaultOptions().WithExtension(ext("first", nil)).WithExtension(ext("second", nil))
+	require.NoError(t, Configure(o))
+	assert.Equal(t, []string{"first", "second"}, applied)
+	
+	require.NoError(t, Configure(DefaultOptions()))
+	assert.Equal(t, []string{"second", "first"}, closed)
+	
+	o = DefaultOptions().WithExtension(ext("failed", errors.New("boom")))
+	assert.ErrorContains(t, Configure(o), "boom")
+}
@@ -0,0 +1,73 @@
+package lager
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type (
+	// contextFieldsKey the context key of the fields carried by the context.
+	contextFieldsKey struct{}
+)
+
+// WithContext returns a copy of the context carrying the fields, e.g. the request id, the tenant or the user,
+// in addition to the ones carried by the parent, which are picked up by the *Ctx methods of the scopes and the FromContext.
+func WithContext(ctx context.Context, fields ...zap.Field) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+
+	prev := ContextFields(ctx)
+	all := make([]zap.Field, 0, len(prev)+len(fields))
+	all = append(all, prev...)
+	all = append(all, fields...)
+
+	return context.WithValue(ctx, contextFieldsKey{}, all)
+}
+
+// ContextFields returns the fields carried by the context, the returned slice must not be modified.
+func ContextFields(ctx context.Context) []zap.Field {
+	if ctx == nil {
+		return nil
+	}
+
+	fields, _ := ctx.Value(contextFieldsKey{}).([]zap.Field)
+	return fields
+}
+
+// FromContext returns the global zap logger with the fields carried by the context and the trace correlation fields,
+// e.g. for the handlers which used to receive a *zap.Logger parameter.
+// unlike the *Ctx methods of the scopes, its entries aren't recorded as span events.
+func FromContext(ctx context.Context) *zap.Logger {
+	fields := contextFields(ctx)
+	if len(fields) == 0 {
+		return zap.L()
+	}
+
+	return zap.L().With(fields...)
+}
+
+// contextFields returns the fields of the entries logged by the context-aware API:
+// the fields carried by the context, and the trace correlation fields of the span carried by the context.
+func contextFields(ctx context.Context) []zap.Field {
+	fields, traced := ContextFields(ctx), traceFields(ctx)
+	if len(traced) == 0 {
+		return fields
+	}
+
+	return append(append(make([]zap.Field, 0, len(fields)+len(traced)), fields...), traced...)
+}
+
+// withContextFields returns the fields of the context followed by the fields of the entry.
+func withContextFields(ctx context.Context, fields []zap.Field) []zap.Field {
+	ctxFields := contextFields(ctx)
+	if len(ctxFields) == 0 {
+		return fields
+	}
+
+	all := make([]zap.Field, 0, len(ctxFields)+len(fields))
+	all = append(all, ctxFields...)
+
+	return append(all, fields...)
+}
@@ -0,0 +1,44 @@
+package lager
+
+import (
+	"context"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWithContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, ContextFields(ctx))
+	assert.Equal(t, ctx, WithContext(ctx))
+	
+	parent := WithContext(ctx, zap.String("request_id", "r1"))
+	child := WithContext(parent, zap.String("tenant", "a"))
+	sibling := WithContext(parent, zap.String("user", "u"))
+	
+	assert.Equal(t, []zap.Field{zap.String("request_id", "r1")}, ContextFields(parent))
+	assert.Equal(t, []zap.Field{zap.String("request_id", "r1"), zap.String("tenant", "a")}, ContextFields(child))
+	assert.Equal(t, []zap.Field{zap.String("request_id", "r1"), zap.String("user", "u")}, ContextFields(sibling))
+}
+
+func TestContextLogging(t *testing.T) {
+	s := RegisterScope("test-ctx", "")
+	buf := configureBuffer(t, DefaultOptions())
+	
+	ctx := WithContext(context.Background(), zap.String("request_id", "r1"))
+	s.InfoCtx(ctx, "scope", zap.Int("count", 1))
+	s.DebugCtx(ctx, "filtered")
+	s.ErrorCtx(context.Background(), "no fields")
+	FromContext(ctx).Warn("global")
+	
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "r1", entries[0]["request_id"])
+	assert.Equal(t, float64(1), entries[0]["count"])
+	assert.Equal(t, "test-ctx", entries[0]["scope"])
+	assert.NotContains(t, entries[1], "request_id")
+	assert.Equal(t, "r1", entries[2]["request_id"])
+	assert.Equal(t, "warn", entries[2]["level"])
+}
@@ -0,0 +1,259 @@
+package lager
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// the keys of the fields of the entry collapsing the duplicates.
+	DedupRepeatedKey  = "repeated"
+	DedupFirstTimeKey = "first_time"
+	DedupLastTimeKey  = "last_time"
+)
+
+// coreType the type of the zapcore.Core, of which a tee is a slice.
+var coreType = reflect.TypeOf((*zapcore.Core)(nil)).Elem()
+
+type (
+	// dedupKey identifies the duplicates, the fields are left out, e.g. the attempt of a retry.
+	dedupKey struct {
+		level zapcore.Level
+		scope string
+		msg   string
+	}
+
+	// dedupRun the duplicates of an entry suppressed since it was written.
+	dedupRun struct {
+		// the core, the entry and the fields of the last duplicate.
+		core   zapcore.Core
+		entry  zapcore.Entry
+		fields []zapcore.Field
+
+		repeated    int
+		first, last time.Time
+		// the end of the window of the run, zero for the consecutive duplicates.
+		expires time.Time
+	}
+
+	// dedupState the runs shared by the dedupCore and its With.
+	dedupState struct {
+		mu     sync.Mutex
+		window time.Duration
+		runs   map[dedupKey]*dedupRun
+		// the key of the latest run, of the consecutive duplicates.
+		latest dedupKey
+
+		// timer flushes the runs whose windows expired, it's armed while any run is pending.
+		timer  *time.Timer
+		armed  bool
+		closed bool
+		// errOut takes the write errors of the runs flushed by the timer, which have no caller to return them to.
+		errOut zapcore.WriteSyncer
+	}
+
+	// dedupCore the zapcore.Core collapsing the duplicates of the entries.
+	dedupCore struct {
+		zapcore.Core
+		state *dedupState
+	}
+)
+
+// NewDedupCore returns a zapcore.Core collapsing the duplicates, the entries of the same level, scope and message
+// regardless of their fields, where
+//   - the first entry is written as-is, and the duplicates are suppressed,
+//   - once the duplicates end, the last one is written with the number of the suppressed ones as the repeated field,
+//     and the times of the first and the last suppressed ones as the first_time and last_time fields,
+//   - the duplicates are the consecutive entries if the window is zero, or else the entries within
+//     the window since the first one, regardless of the entries in between,
+//   - the entries above the error level are never suppressed.
+//
+// the consecutive duplicates end by the next entry or the Sync, so the collapsed entry may be pending until then,
+// while the duplicates within a window end once it expires as well, the write errors of which are reported to stderr.
+func NewDedupCore(core zapcore.Core, window time.Duration) zapcore.Core {
+	return newDedupCore(core, window, zapcore.Lock(os.Stderr))
+}
+
+// newDedupCore returns the dedupCore reporting the write errors of the expired runs to the error output.
+func newDedupCore(core zapcore.Core, window time.Duration, errOut zapcore.WriteSyncer) *dedupCore {
+	return &dedupCore{Core: core, state: &dedupState{window: window, runs: make(map[dedupKey]*dedupRun), errOut: errOut}}
+}
+
+// With impls zapcore.Core.
+func (c *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dedupCore{Core: c.Core.With(fields), state: c.state}
+}
+
+// Check impls zapcore.Core.
+func (c *dedupCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if e.Level > zapcore.ErrorLevel {
+		return c.Core.Check(e, ce)
+	}
+
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+
+	return ce
+}
+
+// Write impls zapcore.Core, the errors of the pending duplicates ended by the entry are returned as well.
+func (c *dedupCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	key := dedupKey{level: e.Level, scope: e.LoggerName, msg: e.Message}
+
+	s := c.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if s.window > 0 {
+		err = s.flushExpired(e.Time)
+	} else if key != s.latest {
+		err = s.flush(s.latest)
+	}
+	s.latest = key
+
+	if run, ok := s.runs[key]; ok {
+		if run.repeated == 0 {
+			run.first = e.Time
+		}
+		run.repeated++
+		run.last = e.Time
+		run.core, run.entry, run.fields = c.Core, e, append(run.fields[:0], fields...)
+		return err
+	}
+
+	run := &dedupRun{}
+	s.runs[key] = run
+	if s.window > 0 {
+		run.expires = e.Time.Add(s.window)
+		s.schedule()
+	}
+
+	return errors.CombineErrors(err, writeEnabled(c.Core, e, fields))
+}
+
+// Sync impls zapcore.Core, which writes the pending duplicates.
+func (c *dedupCore) Sync() error {
+	s := c.state
+	s.mu.Lock()
+	var err error
+	for key := range s.runs {
+		err = errors.CombineErrors(err, s.flush(key))
+	}
+	s.mu.Unlock()
+
+	return errors.CombineErrors(err, c.Core.Sync())
+}
+
+// Close stops the timer of the windows, and writes the pending duplicates by the Sync.
+func (c *dedupCore) Close() error {
+	s := c.state
+	s.mu.Lock()
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.armed = false
+	s.mu.Unlock()
+
+	return c.Sync()
+}
+
+// schedule arms the timer to the earliest expiry of the runs, unless it's armed or closed already.
+func (s *dedupState) schedule() {
+	if s.armed || s.closed || len(s.runs) == 0 {
+		return
+	}
+
+	var next time.Time
+	for _, run := range s.runs {
+		if next.IsZero() || run.expires.Before(next) {
+			next = run.expires
+		}
+	}
+
+	d := time.Until(next)
+	if s.timer == nil {
+		s.timer = time.AfterFunc(d, s.expire)
+	} else {
+		s.timer.Reset(d)
+	}
+	s.armed = true
+}
+
+// expire flushes the runs whose windows expired, and rearms the timer for the rest.
+func (s *dedupState) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.armed = false
+	if s.closed {
+		return
+	}
+
+	now := time.Now()
+	if err := s.flushExpired(now); err != nil {
+		_, _ = fmt.Fprintf(s.errOut, "%v failed to write the duplicates: %v\n", now, err)
+		_ = s.errOut.Sync()
+	}
+	s.schedule()
+}
+
+// flushExpired ends the runs whose windows expired by the time.
+func (s *dedupState) flushExpired(now time.Time) error {
+	var err error
+	for key, run := range s.runs {
+		if !now.Before(run.expires) {
+			err = errors.CombineErrors(err, s.flush(key))
+		}
+	}
+
+	return err
+}
+
+// flush ends the run of the key, and writes its duplicates if any.
+func (s *dedupState) flush(key dedupKey) error {
+	run, ok := s.runs[key]
+	if !ok {
+		return nil
+	}
+	delete(s.runs, key)
+
+	if run.repeated == 0 {
+		return nil
+	}
+
+	fields := append(run.fields,
+		zap.Int(DedupRepeatedKey, run.repeated),
+		zap.Time(DedupFirstTimeKey, run.first),
+		zap.Time(DedupLastTimeKey, run.last))
+	return writeEnabled(run.core, run.entry, fields)
+}
+
+// writeEnabled writes the entry to the core if enabled by the core, and returns the errors of the writes.
+// the wrapper cores, e.g. the redaction and the dedup, write the entries they altered or delayed by this,
+// rather than by the Write of the wrapped core, so that the levels of its cores, e.g. the ones of a tee, are honored,
+// since the Write of a tee writes to all of its cores regardless of their levels.
+func writeEnabled(core zapcore.Core, e zapcore.Entry, fields []zapcore.Field) error {
+	if v := reflect.ValueOf(core); v.Kind() == reflect.Slice && v.Type().Elem() == coreType {
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			err = errors.CombineErrors(err, writeEnabled(v.Index(i).Interface().(zapcore.Core), e, fields))
+		}
+		return err
+	}
+
+	if !core.Enabled(e.Level) {
+		return nil
+	}
+
+	return core.Write(e, fields)
+}
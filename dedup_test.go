@@ -0,0 +1,156 @@
+package lager
+
+import (
+	"io"
+	"testing"
+	"time"
+	
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDedupCoreConsecutive(t *testing.T) {
+	obs, logs := observer.New(zapcore.InfoLevel)
+	core := NewDedupCore(obs, 0)
+	logger := zap.New(core)
+	
+	start := time.Date(2022, 9, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		if ce := logger.Check(zapcore.ErrorLevel, "retry failed"); ce != nil {
+			ce.Time = start.Add(time.Duration(i) * time.Second)
+			ce.Write(zap.Int("attempt", i))
+		}
+	}
+	logger.Named("ads").Error("retry failed")
+	logger.Info("done")
+	logger.Info("done")
+	logger.Debug("disabled")
+	require.NoError(t, core.Sync())
+	
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 5)
+	assert.Equal(t, map[string]interface{}{"attempt": int64(0)}, entries[0].ContextMap())
+	
+	assert.Equal(t, "retry failed", entries[1].Message)
+	assert.Equal(t, map[string]interface{}{
+		"attempt":         int64(3),
+		DedupRepeatedKey:  int64(3),
+		DedupFirstTimeKey: start.Add(time.Second),
+		DedupLastTimeKey:  start.Add(3 * time.Second),
+	}, entries[1].ContextMap())
+	
+	assert.Equal(t, "ads", entries[2].LoggerName, "expected the scope identifying the duplicates")
+	assert.Equal(t, "done", entries[3].Message)
+	assert.NotContains(t, entries[3].ContextMap(), DedupRepeatedKey)
+	assert.Equal(t, int64(1), entries[4].ContextMap()[DedupRepeatedKey], "expected the pending duplicates written by the Sync")
+}
+
+func TestDedupCoreWindow(t *testing.T) {
+	obs, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(NewDedupCore(obs, time.Minute))
+	
+	// the entries are within the window of the wall clock, so that the timer doesn't expire them.
+	start := time.Now()
+	write := func(msg string, after time.Duration) {
+		if ce := logger.Check(zapcore.WarnLevel, msg); ce != nil {
+			ce.Time = start.Add(after)
+			ce.Write()
+		}
+	}
+	
+	write("flapping", 0)
+	write("other", time.Second)
+	write("flapping", 2*time.Second)
+	write("flapping", 3*time.Second)
+	write("flapping", time.Minute+time.Second)
+	
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 4)
+	assert.Equal(t, "flapping", entries[0].Message)
+	assert.Equal(t, "other", entries[1].Message)
+	assert.Equal(t, "flapping", entries[2].Message)
+	assert.Equal(t, int64(2), entries[2].ContextMap()[DedupRepeatedKey])
+	assert.Equal(t, start.Add(3*time.Second), logs.All()[2].Time)
+	assert.Equal(t, "flapping", entries[3].Message)
+	assert.NotContains(t, entries[3].ContextMap(), DedupRepeatedKey, "expected a new window")
+}
+
+func TestDedupCoreWindowExpired(t *testing.T) {
+	obs, logs := observer.New(zapcore.InfoLevel)
+	core := newDedupCore(obs, 10*time.Millisecond, zapcore.AddSync(io.Discard))
+	logger := zap.New(core)
+	
+	logger.Warn("flapping")
+	logger.Warn("flapping")
+	require.Eventually(t, func() bool { return logs.Len() == 2 }, time.Second, time.Millisecond,
+		"expected the duplicates written once the window expired")
+	assert.Equal(t, int64(1), logs.AllUntimed()[1].ContextMap()[DedupRepeatedKey])
+	
+	logger.Warn("flapping")
+	logger.Warn("flapping")
+	require.NoError(t, core.Close())
+	require.Equal(t, 4, logs.Len(), "expected the pending duplicates written by the Close")
+	
+	logger.Warn("flapping")
+	logger.Warn("flapping")
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, 5, logs.Len(), "expected the timer stopped by the Close")
+}
+
+// errDiskFull the error of the failingWriter.
+var errDiskFull = errors.New("disk full")
+
+// failingWriter a zapcore.WriteSyncer failing every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errDiskFull
+}
+
+func (failingWriter) Sync() error {
+	return nil
+}
+
+func TestDedupCoreHonorsWrappedLevels(t *testing.T) {
+	info, infoLogs := observer.New(zapcore.InfoLevel)
+	debug, debugLogs := observer.New(zapcore.DebugLevel)
+	core := NewDedupCore(zapcore.NewTee(info, debug), 0)
+	logger := zap.New(core)
+	
+	logger.Debug("debug")
+	logger.Debug("debug")
+	logger.Info("info")
+	
+	assert.Equal(t, 1, infoLogs.Len())
+	assert.Equal(t, 3, debugLogs.Len())
+	assert.Equal(t, int64(1), debugLogs.AllUntimed()[1].ContextMap()[DedupRepeatedKey])
+}
+
+func TestDedupCoreWriteErrors(t *testing.T) {
+	core := NewDedupCore(zapcore.NewCore(zapcore.NewJSONEncoder(defaultEncoderConfig()), failingWriter{}, zapcore.DebugLevel), 0)
+	e := zapcore.Entry{Level: zapcore.InfoLevel, Message: "lost"}
+	
+	assert.ErrorIs(t, core.Write(e, nil), errDiskFull, "expected the errors of the wrapped core returned")
+	require.NoError(t, core.Write(e, nil), "expected the duplicate suppressed")
+	assert.ErrorIs(t, core.Sync(), errDiskFull, "expected the errors of the pending duplicates returned")
+}
+
+func TestConfigureDedup(t *testing.T) {
+	s := RegisterScope("test-dedup", "")
+	buf := configureBuffer(t, DefaultOptions().WithDedup(0))
+	
+	for i := 0; i < 3; i++ {
+		s.Info("repeated")
+	}
+	require.Len(t, decodeLines(t, buf), 1)
+	
+	require.NoError(t, Configure(DefaultOptions()))
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 2, "expected the pending duplicates written by the next Configure")
+	assert.Equal(t, float64(2), entries[1][DedupRepeatedKey])
+	assert.Equal(t, "test-dedup", entries[1]["scope"])
+}
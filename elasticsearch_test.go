@@ -0,0 +1,126 @@
+package lager
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dapings/lager/batch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestElasticsearchSink(t *testing.T) {
+	receiver := newPushReceiver(t)
+	receiver.failures.Store(1)
+	receiver.status = http.StatusTooManyRequests
+	receiver.response = `{"errors":false,"items":[]}`
+
+	o := DefaultOptions().WithElasticsearchOutput(ElasticsearchConfig{
+		URL:     receiver.URL,
+		Index:   "my-app",
+		Headers: map[string]string{"Authorization": "ApiKey key"},
+		Batch:   batch.Config{FlushInterval: time.Hour, InitialBackoff: time.Millisecond},
+	})
+	o.OutputPaths = nil
+	require.NoError(t, Configure(o))
+
+	zap.L().Named("ads").Error("hello", zap.Int("count", 3))
+	require.NoError(t, zap.L().Sync())
+	require.NoError(t, Configure(DefaultOptions()))
+
+	requests, bodies := receiver.received()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/_bulk", requests[0].URL.Path)
+	assert.Equal(t, elasticsearchBulkContentType, requests[0].Header.Get("Content-Type"))
+	assert.Equal(t, "ApiKey key", requests[0].Header.Get("Authorization"))
+
+	lines := strings.Split(strings.TrimSuffix(bodies[0], "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.Regexp(t, `^\{"index":\{"_index":"my-app-\d{4}\.\d{2}\.\d{2}"\}\}$`, lines[0])
+	assert.Contains(t, lines[1], `"@timestamp":`)
+	assert.Contains(t, lines[1], `"message":"hello"`)
+	assert.Contains(t, lines[1], `"scope":"ads"`)
+	assert.Contains(t, lines[1], `"count":3`)
+}
+
+func TestElasticsearchSinkRejectedDocuments(t *testing.T) {
+	receiver := newPushReceiver(t)
+	receiver.response = `{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}]}`
+
+	_, entrySinks, closeFunc, err := openSinks("elasticsearch://" + strings.TrimPrefix(receiver.URL, "http://") +
+		"?index=logs&indexDateLayout=2006&flushInterval=1h")
+	require.NoError(t, err)
+	defer closeFunc()
+	require.Len(t, entrySinks, 1)
+
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC), Message: "hello"}
+	require.NoError(t, entrySinks[0].WriteEntry(ent, nil))
+	require.NoError(t, entrySinks[0].WriteEntry(ent, nil))
+
+	err = entrySinks[0].Sync()
+	assert.True(t, batch.IsPermanent(err))
+	assert.ErrorContains(t, err, "1 of 2 documents rejected")
+	assert.ErrorContains(t, err, "mapper_parsing_exception")
+
+	requests, bodies := receiver.received()
+	require.Len(t, requests, 1, "expected no retry")
+	assert.Contains(t, bodies[0], `"_index":"logs-2022"`)
+}
+
+func TestElasticsearchSinkThrottledDocuments(t *testing.T) {
+	receiver := newPushReceiver(t)
+	receiver.responses = []string{
+		`{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":429,"error":{"type":"es_rejected_execution_exception"}}},{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}]}`,
+		`{"errors":true,"items":[{"index":{"status":503,"error":{"type":"unavailable_shards_exception"}}}]}`,
+	}
+	receiver.response = `{"errors":false,"items":[{"index":{"status":201}}]}`
+
+	s, err := NewElasticsearchSink(ElasticsearchConfig{
+		URL:   receiver.URL,
+		Batch: batch.Config{FlushInterval: time.Hour, InitialBackoff: time.Millisecond},
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	for _, msg := range []string{"indexed", "throttled", "malformed"} {
+		require.NoError(t, s.WriteEntry(zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now(), Message: msg}, nil))
+	}
+
+	err = s.Sync()
+	assert.True(t, batch.IsPermanent(err))
+	assert.ErrorContains(t, err, "1 of 3 documents rejected")
+	assert.ErrorContains(t, err, "mapper_parsing_exception")
+
+	_, bodies := receiver.received()
+	require.Len(t, bodies, 3, "expected the throttled document retried until indexed")
+	for _, body := range bodies[1:] {
+		assert.Contains(t, body, `"message":"throttled"`)
+		assert.NotContains(t, body, `"message":"indexed"`)
+		assert.NotContains(t, body, `"message":"malformed"`)
+	}
+}
+
+func TestElasticsearchSinkErrors(t *testing.T) {
+	receiver := newPushReceiver(t)
+	receiver.failures.Store(10)
+	receiver.status = http.StatusUnauthorized
+
+	s, err := NewElasticsearchSink(ElasticsearchConfig{URL: receiver.URL, Batch: batch.Config{FlushInterval: time.Hour}})
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.WriteEntry(zapcore.Entry{Message: "hello"}, nil))
+	err = s.Sync()
+	assert.True(t, batch.IsPermanent(err))
+	assert.ErrorContains(t, err, "401")
+
+	_, err = NewElasticsearchSink(ElasticsearchConfig{URL: "localhost:9200"})
+	assert.Error(t, err)
+
+	_, _, _, err = openSinks("elasticsearch://?index=foo")
+	assert.Error(t, err)
+}
@@ -0,0 +1,351 @@
+package experiments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/dapings/lager/batch"
+	"github.com/dapings/lager/common"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultOTLPEndpoint = "http://localhost:4318/v1/logs"
+	defaultOTLPTimeout  = 10 * time.Second
+	otlpCloseTimeout    = 30 * time.Second
+
+	otlpProtobufContentType = "application/x-protobuf"
+	otlpJSONContentType     = "application/json"
+
+	// the semantic conventions of the resource attributes.
+	otlpServiceNameKey       = "service.name"
+	otlpServiceVersionKey    = "service.version"
+	otlpServiceInstanceIDKey = "service.instance.id"
+
+	// otlpDroppedKey the key of the field carrying the number of the dropped records of a summary.
+	otlpDroppedKey = "dropped"
+)
+
+// levelToOTLPSeverity maps the levels to the OTel severity numbers and texts.
+var levelToOTLPSeverity = map[zapcore.Level]struct {
+	number int32
+	text   string
+}{
+	zapcore.DebugLevel:  {5, "DEBUG"},
+	zapcore.InfoLevel:   {9, "INFO"},
+	zapcore.WarnLevel:   {13, "WARN"},
+	zapcore.ErrorLevel:  {17, "ERROR"},
+	zapcore.DPanicLevel: {20, "ERROR4"},
+	zapcore.PanicLevel:  {21, "FATAL"},
+	zapcore.FatalLevel:  {21, "FATAL"},
+}
+
+type (
+	// OTLPConfig the config of the OTLP log exporter.
+	OTLPConfig struct {
+		// the OTLP/HTTP logs endpoint, default http://localhost:4318/v1/logs.
+		Endpoint string
+
+		// whether the records are encoded as the OTLP/JSON instead of the protobuf.
+		JSONEncoding bool
+
+		// the additional request headers, e.g. the authorization.
+		Headers map[string]string
+
+		// the resource attributes: service.name, service.version, service.instance.id.
+		AppID    string
+		Version  string
+		Instance string
+
+		// the batching and retry config.
+		Batch batch.Config
+
+		// the client sending the requests, default a client with 10s timeout.
+		HTTPClient *http.Client
+	}
+
+	// otlpExporter exports the log records in batches to an OTLP/HTTP endpoint.
+	otlpExporter struct {
+		cfg      OTLPConfig
+		resource otlpResource
+		batcher  *batch.Batcher[otlpEntry]
+	}
+
+	// otlpEntry an entry queued by the core.
+	otlpEntry struct {
+		scope  string
+		record otlpLogRecord
+	}
+
+	// otlpCore writes entries to an OTLP exporter.
+	otlpCore struct {
+		exporter     *otlpExporter
+		minimumLevel zapcore.Level
+		fields       []zapcore.Field
+	}
+)
+
+// TeeToOTLP returns a zapcore.Core that writes the entries to the provided core and
+// exports them as the OpenTelemetry log records over the OTLP/HTTP,
+// the returned common.CloseFunc exports the pending records and stops the exporter.
+// the records dropped by the full queue are reported to the provided core by a "dropped N messages" summary
+// at the warn level, unless the batch config has its own OnDrop.
+func TeeToOTLP(baseCore zapcore.Core, cfg OTLPConfig) (zapcore.Core, common.CloseFunc, error) {
+	if cfg.Batch.OnDrop == nil {
+		cfg.Batch.OnDrop = func(n uint64) {
+			reportOTLPDrops(baseCore, n)
+		}
+	}
+
+	exporter, err := newOTLPExporter(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	oc := &otlpCore{exporter: exporter}
+	for l := zapcore.DebugLevel; l <= zapcore.FatalLevel; l++ {
+		if baseCore.Enabled(l) {
+			oc.minimumLevel = l
+			break
+		}
+	}
+
+	return zapcore.NewTee(baseCore, oc), exporter.close, nil
+}
+
+// OTLPExtension returns the extension exporting the log to an OTLP/HTTP endpoint,
+// which can be registered by the lager.Options WithExtension.
+func OTLPExtension(cfg OTLPConfig) common.Extension {
+	return func(core zapcore.Core) (zapcore.Core, common.CloseFunc, error) {
+		return TeeToOTLP(core, cfg)
+	}
+}
+
+// reportOTLPDrops writes the summary of the dropped records to the core.
+func reportOTLPDrops(core zapcore.Core, n uint64) {
+	e := zapcore.Entry{
+		Level:   zapcore.WarnLevel,
+		Time:    time.Now(),
+		Message: "the OTLP exporter dropped " + strconv.FormatUint(n, 10) + " messages",
+	}
+	if ce := core.Check(e, nil); ce != nil {
+		ce.Write(zap.Uint64(otlpDroppedKey, n))
+	}
+}
+
+// newOTLPExporter returns a started exporter.
+func newOTLPExporter(cfg OTLPConfig) (*otlpExporter, error) {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultOTLPEndpoint
+	}
+
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid OTLP endpoint")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("the OTLP endpoint must be an http or https URL: %q", cfg.Endpoint)
+	}
+
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: defaultOTLPTimeout}
+	}
+
+	attrs := map[string]interface{}{}
+	for k, v := range map[string]string{
+		otlpServiceNameKey:       cfg.AppID,
+		otlpServiceVersionKey:    cfg.Version,
+		otlpServiceInstanceIDKey: cfg.Instance,
+	} {
+		if v != "" {
+			attrs[k] = v
+		}
+	}
+
+	e := &otlpExporter{cfg: cfg, resource: otlpResource{Attributes: otlpAttributes(attrs)}}
+	e.batcher = batch.New(cfg.Batch, e.export)
+
+	return e, nil
+}
+
+// export sends the entries grouped by the scope within a single request.
+func (e *otlpExporter) export(ctx context.Context, entries []otlpEntry) error {
+	rl := otlpResourceLogs{Resource: e.resource}
+	scopes := map[string]int{}
+	for _, entry := range entries {
+		i, ok := scopes[entry.scope]
+		if !ok {
+			i = len(rl.ScopeLogs)
+			scopes[entry.scope] = i
+			rl.ScopeLogs = append(rl.ScopeLogs, otlpScopeLogs{Scope: otlpInstrumentationScope{Name: entry.scope}})
+		}
+
+		rl.ScopeLogs[i].LogRecords = append(rl.ScopeLogs[i].LogRecords, entry.record)
+	}
+
+	req := &otlpExportRequest{ResourceLogs: []otlpResourceLogs{rl}}
+
+	var (
+		body        []byte
+		contentType = otlpProtobufContentType
+	)
+	if e.cfg.JSONEncoding {
+		var err error
+		if body, err = json.Marshal(req); err != nil {
+			return batch.Permanent(errors.Wrap(err, "failed to encode the OTLP request"))
+		}
+		contentType = otlpJSONContentType
+	} else {
+		body = req.appendProto(nil)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return batch.Permanent(err)
+	}
+
+	httpReq.Header.Set("Content-Type", contentType)
+	for k, v := range e.cfg.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := e.cfg.HTTPClient.Do(httpReq)
+	if err != nil {
+		return errors.Wrap(err, "failed to send the OTLP request")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		return errors.Errorf("retryable OTLP response status: %s", resp.Status)
+	default:
+		return batch.Permanent(errors.Errorf("OTLP response status: %s", resp.Status))
+	}
+}
+
+// close exports the pending records and stops the exporter.
+func (e *otlpExporter) close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), otlpCloseTimeout)
+	defer cancel()
+
+	return e.batcher.Close(ctx)
+}
+
+// Enabled impls zapcore.Core.
+func (oc *otlpCore) Enabled(l zapcore.Level) bool {
+	return l >= oc.minimumLevel
+}
+
+// With impls zapcore.Core.
+func (oc *otlpCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(oc.fields)+len(fields))
+	all = append(all, oc.fields...)
+
+	return &otlpCore{
+		exporter:     oc.exporter,
+		minimumLevel: oc.minimumLevel,
+		fields:       append(all, fields...),
+	}
+}
+
+// Check impls zapcore.Core.
+func (oc *otlpCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if oc.Enabled(e.Level) {
+		return ce.AddCore(e, oc)
+	}
+
+	return ce
+}
+
+// Write impls zapcore.Core and
+// queues the entry as a log record, the record is dropped once the queue is full, which is reported periodically
+// rather than failing every write.
+func (oc *otlpCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range oc.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	traceID, spanID, flags := otlpTraceFields(enc.Fields)
+
+	severity, ok := levelToOTLPSeverity[e.Level]
+	if !ok {
+		severity = levelToOTLPSeverity[zapcore.InfoLevel]
+	}
+
+	record := otlpLogRecord{
+		TimeUnixNano:         uint64(e.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       severity.number,
+		SeverityText:         severity.text,
+		Body:                 otlpString(e.Message),
+		Attributes:           otlpAttributes(enc.Fields),
+		TraceID:              traceID,
+		SpanID:               spanID,
+		Flags:                flags,
+	}
+
+	if e.Caller.Defined {
+		record.Attributes = append(record.Attributes,
+			otlpKeyValue{Key: "code.filepath", Value: otlpString(e.Caller.File)},
+			otlpKeyValue{Key: "code.lineno", Value: otlpInt(int64(e.Caller.Line))})
+	}
+
+	if e.Stack != "" {
+		record.Attributes = append(record.Attributes, otlpKeyValue{Key: "exception.stacktrace", Value: otlpString(e.Stack)})
+	}
+
+	oc.exporter.batcher.Add(otlpEntry{scope: e.LoggerName, record: record})
+	return nil
+}
+
+// otlpTraceFields removes the trace correlation fields from the fields,
+// and returns them as the trace id, span id and flags of the log record.
+func otlpTraceFields(fields map[string]interface{}) (traceID, spanID string, flags uint32) {
+	traceID, _ = fields[common.TraceIDKey].(string)
+	spanID, _ = fields[common.SpanIDKey].(string)
+	if traceID == "" || spanID == "" {
+		return "", "", 0
+	}
+
+	if v, ok := fields[common.TraceFlagsKey].(string); ok {
+		if f, err := strconv.ParseUint(v, 16, 8); err == nil {
+			flags = uint32(f)
+		}
+	}
+
+	delete(fields, common.TraceIDKey)
+	delete(fields, common.SpanIDKey)
+	delete(fields, common.TraceFlagsKey)
+
+	return traceID, spanID, flags
+}
+
+// Sync impls zapcore.Core and exports the pending records.
+func (oc *otlpCore) Sync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), otlpCloseTimeout)
+	defer cancel()
+
+	if err := oc.exporter.batcher.Flush(ctx); err != nil {
+		return errors.Wrap(err, "failed to export the OTLP log records")
+	}
+
+	return nil
+}
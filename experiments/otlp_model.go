@@ -0,0 +1,302 @@
+package experiments
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// the subset of the OTLP logs data model written by the OTLP exporter,
+// see https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/logs/v1/logs.proto.
+//
+// the structs are encoded either as the OTLP/JSON by the encoding/json package,
+// or as the protobuf by their appendProto methods.
+type (
+	otlpExportRequest struct {
+		ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+	}
+
+	otlpResourceLogs struct {
+		Resource  otlpResource    `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes,omitempty"`
+	}
+
+	otlpScopeLogs struct {
+		Scope      otlpInstrumentationScope `json:"scope"`
+		LogRecords []otlpLogRecord          `json:"logRecords"`
+	}
+
+	otlpInstrumentationScope struct {
+		Name string `json:"name,omitempty"`
+	}
+
+	otlpLogRecord struct {
+		TimeUnixNano         uint64         `json:"timeUnixNano,string"`
+		ObservedTimeUnixNano uint64         `json:"observedTimeUnixNano,string"`
+		SeverityNumber       int32          `json:"severityNumber"`
+		SeverityText         string         `json:"severityText"`
+		Body                 otlpAnyValue   `json:"body"`
+		Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+		// the hex encoded ids, which are bytes within the protobuf.
+		TraceID string `json:"traceId,omitempty"`
+		SpanID  string `json:"spanId,omitempty"`
+		Flags   uint32 `json:"flags,omitempty"`
+	}
+
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+
+	otlpAnyValue struct {
+		StringValue *string           `json:"stringValue,omitempty"`
+		BoolValue   *bool             `json:"boolValue,omitempty"`
+		IntValue    *int64            `json:"intValue,string,omitempty"`
+		DoubleValue *float64          `json:"doubleValue,omitempty"`
+		ArrayValue  *otlpArrayValue   `json:"arrayValue,omitempty"`
+		KvlistValue *otlpKeyValueList `json:"kvlistValue,omitempty"`
+		BytesValue  []byte            `json:"bytesValue,omitempty"`
+	}
+
+	otlpArrayValue struct {
+		Values []otlpAnyValue `json:"values"`
+	}
+
+	otlpKeyValueList struct {
+		Values []otlpKeyValue `json:"values"`
+	}
+)
+
+// otlpString returns the AnyValue of the string.
+func otlpString(s string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &s}
+}
+
+// otlpValue returns the AnyValue of a field value from the zapcore.MapObjectEncoder.
+func otlpValue(v interface{}) otlpAnyValue {
+	switch v := v.(type) {
+	case string:
+		return otlpString(v)
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int:
+		return otlpInt(int64(v))
+	case int64:
+		return otlpInt(v)
+	case int32:
+		return otlpInt(int64(v))
+	case int16:
+		return otlpInt(int64(v))
+	case int8:
+		return otlpInt(int64(v))
+	case uint:
+		return otlpUint(uint64(v))
+	case uint64:
+		return otlpUint(v)
+	case uint32:
+		return otlpInt(int64(v))
+	case uint16:
+		return otlpInt(int64(v))
+	case uint8:
+		return otlpInt(int64(v))
+	case uintptr:
+		return otlpUint(uint64(v))
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	case float32:
+		f := float64(v)
+		return otlpAnyValue{DoubleValue: &f}
+	case []byte:
+		return otlpAnyValue{BytesValue: v}
+	case time.Time:
+		return otlpString(v.Format(time.RFC3339Nano))
+	case time.Duration:
+		return otlpString(v.String())
+	case []interface{}:
+		values := make([]otlpAnyValue, 0, len(v))
+		for _, e := range v {
+			values = append(values, otlpValue(e))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case map[string]interface{}:
+		return otlpAnyValue{KvlistValue: &otlpKeyValueList{Values: otlpAttributes(v)}}
+	case error:
+		return otlpString(v.Error())
+	case fmt.Stringer:
+		return otlpString(v.String())
+	default:
+		return otlpString(fmt.Sprint(v))
+	}
+}
+
+// otlpInt returns the AnyValue of the integer.
+func otlpInt(i int64) otlpAnyValue {
+	return otlpAnyValue{IntValue: &i}
+}
+
+// otlpUint returns the AnyValue of the unsigned integer, which is a string once it overflows the int64.
+func otlpUint(u uint64) otlpAnyValue {
+	if u > math.MaxInt64 {
+		return otlpString(strconv.FormatUint(u, 10))
+	}
+
+	return otlpInt(int64(u))
+}
+
+// otlpAttributes returns the KeyValues of the map sorted by the keys.
+func otlpAttributes(m map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, otlpKeyValue{Key: k, Value: otlpValue(m[k])})
+	}
+
+	return attrs
+}
+
+// appendProto appends the protobuf of the ExportLogsServiceRequest.
+func (r *otlpExportRequest) appendProto(b []byte) []byte {
+	for i := range r.ResourceLogs {
+		b = appendProtoMessage(b, 1, r.ResourceLogs[i].appendProto)
+	}
+
+	return b
+}
+
+// appendProto appends the protobuf of the ResourceLogs.
+func (r *otlpResourceLogs) appendProto(b []byte) []byte {
+	b = appendProtoMessage(b, 1, r.Resource.appendProto)
+	for i := range r.ScopeLogs {
+		b = appendProtoMessage(b, 2, r.ScopeLogs[i].appendProto)
+	}
+
+	return b
+}
+
+// appendProto appends the protobuf of the Resource.
+func (r *otlpResource) appendProto(b []byte) []byte {
+	for i := range r.Attributes {
+		b = appendProtoMessage(b, 1, r.Attributes[i].appendProto)
+	}
+
+	return b
+}
+
+// appendProto appends the protobuf of the ScopeLogs.
+func (s *otlpScopeLogs) appendProto(b []byte) []byte {
+	b = appendProtoMessage(b, 1, s.Scope.appendProto)
+	for i := range s.LogRecords {
+		b = appendProtoMessage(b, 2, s.LogRecords[i].appendProto)
+	}
+
+	return b
+}
+
+// appendProto appends the protobuf of the InstrumentationScope.
+func (s *otlpInstrumentationScope) appendProto(b []byte) []byte {
+	return appendProtoString(b, 1, s.Name)
+}
+
+// appendProto appends the protobuf of the LogRecord.
+func (r *otlpLogRecord) appendProto(b []byte) []byte {
+	b = protowire.AppendTag(b, 1, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, r.TimeUnixNano)
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(r.SeverityNumber))
+	b = appendProtoString(b, 3, r.SeverityText)
+	b = appendProtoMessage(b, 5, r.Body.appendProto)
+	for i := range r.Attributes {
+		b = appendProtoMessage(b, 6, r.Attributes[i].appendProto)
+	}
+
+	if r.Flags != 0 {
+		b = protowire.AppendTag(b, 8, protowire.Fixed32Type)
+		b = protowire.AppendFixed32(b, r.Flags)
+	}
+
+	if id, err := hex.DecodeString(r.TraceID); err == nil && len(id) > 0 {
+		b = protowire.AppendTag(b, 9, protowire.BytesType)
+		b = protowire.AppendBytes(b, id)
+	}
+
+	if id, err := hex.DecodeString(r.SpanID); err == nil && len(id) > 0 {
+		b = protowire.AppendTag(b, 10, protowire.BytesType)
+		b = protowire.AppendBytes(b, id)
+	}
+
+	b = protowire.AppendTag(b, 11, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, r.ObservedTimeUnixNano)
+}
+
+// appendProto appends the protobuf of the KeyValue.
+func (kv *otlpKeyValue) appendProto(b []byte) []byte {
+	b = appendProtoString(b, 1, kv.Key)
+	return appendProtoMessage(b, 2, kv.Value.appendProto)
+}
+
+// appendProto appends the protobuf of the AnyValue.
+func (v *otlpAnyValue) appendProto(b []byte) []byte {
+	switch {
+	case v.StringValue != nil:
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, *v.StringValue)
+	case v.BoolValue != nil:
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(*v.BoolValue))
+	case v.IntValue != nil:
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(*v.IntValue))
+	case v.DoubleValue != nil:
+		b = protowire.AppendTag(b, 4, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(*v.DoubleValue))
+	case v.ArrayValue != nil:
+		b = appendProtoMessage(b, 5, func(b []byte) []byte {
+			for i := range v.ArrayValue.Values {
+				b = appendProtoMessage(b, 1, v.ArrayValue.Values[i].appendProto)
+			}
+			return b
+		})
+	case v.KvlistValue != nil:
+		b = appendProtoMessage(b, 6, func(b []byte) []byte {
+			for i := range v.KvlistValue.Values {
+				b = appendProtoMessage(b, 1, v.KvlistValue.Values[i].appendProto)
+			}
+			return b
+		})
+	case v.BytesValue != nil:
+		b = protowire.AppendTag(b, 7, protowire.BytesType)
+		b = protowire.AppendBytes(b, v.BytesValue)
+	}
+
+	return b
+}
+
+// appendProtoString appends the string field, unless it's empty.
+func appendProtoString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendProtoMessage appends the length-delimited message field, whose content is appended by the function.
+func appendProtoMessage(b []byte, num protowire.Number, appendContent func([]byte) []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, appendContent(nil))
+}
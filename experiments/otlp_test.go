@@ -0,0 +1,256 @@
+package experiments
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	
+	"github.com/dapings/lager/batch"
+	"github.com/dapings/lager/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type otlpReceiver struct {
+	*httptest.Server
+	
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	failures *atomic.Int32
+	status   int
+}
+
+func newOTLPReceiver(t *testing.T) *otlpReceiver {
+	r := &otlpReceiver{failures: atomic.NewInt32(0), status: http.StatusServiceUnavailable}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if r.failures.Dec() >= 0 {
+			w.WriteHeader(r.status)
+			return
+		}
+		
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		r.mu.Unlock()
+	}))
+	t.Cleanup(r.Close)
+	
+	return r
+}
+
+func (r *otlpReceiver) received() ([]*http.Request, [][]byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	return r.requests, r.bodies
+}
+
+// decodeProto decodes the fields of a protobuf message, the length-delimited fields are kept as bytes.
+func decodeProto(t *testing.T, b []byte) map[protowire.Number][]interface{} {
+	fields := map[protowire.Number][]interface{}{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		
+		var v interface{}
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			v, n = protowire.ConsumeFixed32(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("unexpected wire type %v", typ)
+		}
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		fields[num] = append(fields[num], v)
+	}
+	
+	return fields
+}
+
+func TestTeeToOTLPJSON(t *testing.T) {
+	receiver := newOTLPReceiver(t)
+	baseCore, _ := observer.New(zapcore.InfoLevel)
+	
+	core, closeFunc, err := TeeToOTLP(baseCore, OTLPConfig{
+		Endpoint:     receiver.URL + "/v1/logs",
+		JSONEncoding: true,
+		Headers:      map[string]string{"Authorization": "Bearer token"},
+		AppID:        "my-app",
+		Version:      "1.0.0",
+		Instance:     "pod-1",
+		Batch:        batch.Config{FlushInterval: time.Hour},
+	})
+	require.NoError(t, err)
+	
+	logger := zap.New(core)
+	logger.Named("ads").Warn("hello", zap.Int("count", 3), zap.Bool("ok", true), zap.Float64("ratio", 0.5))
+	logger.Named("xds").Info("world", zap.Strings("names", []string{"a", "b"}))
+	logger.Debug("not enabled")
+	require.NoError(t, closeFunc())
+	
+	requests, bodies := receiver.received()
+	require.Len(t, requests, 1)
+	assert.Equal(t, otlpJSONContentType, requests[0].Header.Get("Content-Type"))
+	assert.Equal(t, "Bearer token", requests[0].Header.Get("Authorization"))
+	
+	var req otlpExportRequest
+	require.NoError(t, json.Unmarshal(bodies[0], &req))
+	require.Len(t, req.ResourceLogs, 1)
+	
+	rl := req.ResourceLogs[0]
+	assert.Equal(t, otlpAttributes(map[string]interface{}{
+		"service.name":        "my-app",
+		"service.version":     "1.0.0",
+		"service.instance.id": "pod-1",
+	}), rl.Resource.Attributes)
+	
+	require.Len(t, rl.ScopeLogs, 2)
+	assert.Equal(t, "ads", rl.ScopeLogs[0].Scope.Name)
+	assert.Equal(t, "xds", rl.ScopeLogs[1].Scope.Name)
+	
+	record := rl.ScopeLogs[0].LogRecords[0]
+	assert.Equal(t, int32(13), record.SeverityNumber)
+	assert.Equal(t, "WARN", record.SeverityText)
+	assert.Equal(t, "hello", *record.Body.StringValue)
+	assert.NotZero(t, record.TimeUnixNano)
+	assert.Equal(t, otlpAttributes(map[string]interface{}{"count": int64(3), "ok": true, "ratio": 0.5}), record.Attributes)
+	
+	record = rl.ScopeLogs[1].LogRecords[0]
+	assert.Equal(t, otlpAttributes(map[string]interface{}{"names": []interface{}{"a", "b"}}), record.Attributes)
+	assert.Contains(t, string(bodies[0]), `"intValue":"3"`, "expected the int64 encoded as a string")
+}
+
+func TestTeeToOTLPTraceFields(t *testing.T) {
+	receiver := newOTLPReceiver(t)
+	baseCore, _ := observer.New(zapcore.InfoLevel)
+	
+	core, closeFunc, err := TeeToOTLP(baseCore, OTLPConfig{
+		Endpoint:     receiver.URL + "/v1/logs",
+		JSONEncoding: true,
+		Batch:        batch.Config{FlushInterval: time.Hour},
+	})
+	require.NoError(t, err)
+	
+	logger := zap.New(core).With(zap.String(common.TraceIDKey, "4bf92f3577b34da6a3ce929d0e0e4736"))
+	logger.Info("traced", zap.String(common.SpanIDKey, "00f067aa0ba902b7"), zap.String(common.TraceFlagsKey, "01"), zap.Int("count", 1))
+	logger.Info("no span")
+	require.NoError(t, closeFunc())
+	
+	_, bodies := receiver.received()
+	require.Len(t, bodies, 1)
+	
+	var req otlpExportRequest
+	require.NoError(t, json.Unmarshal(bodies[0], &req))
+	records := req.ResourceLogs[0].ScopeLogs[0].LogRecords
+	require.Len(t, records, 2)
+	
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", records[0].TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", records[0].SpanID)
+	assert.Equal(t, uint32(1), records[0].Flags)
+	assert.Equal(t, otlpAttributes(map[string]interface{}{"count": int64(1)}), records[0].Attributes)
+	
+	// a trace id without span id is left as an attribute.
+	assert.Empty(t, records[1].TraceID)
+	assert.Equal(t, otlpAttributes(map[string]interface{}{common.TraceIDKey: "4bf92f3577b34da6a3ce929d0e0e4736"}), records[1].Attributes)
+}
+
+func TestTeeToOTLPProtobuf(t *testing.T) {
+	receiver := newOTLPReceiver(t)
+	receiver.failures.Store(2)
+	
+	core, closeFunc, err := TeeToOTLP(zapcore.NewNopCore(), OTLPConfig{
+		Endpoint: receiver.URL,
+		AppID:    "my-app",
+		Batch:    batch.Config{FlushInterval: time.Hour, InitialBackoff: time.Millisecond},
+	})
+	require.NoError(t, err)
+	
+	logger := zap.New(core).With(zap.String("tenant", "foo"))
+	logger.Error("hello")
+	require.NoError(t, logger.Sync(), "expected the retries to succeed")
+	
+	requests, bodies := receiver.received()
+	require.Len(t, requests, 1)
+	assert.Equal(t, otlpProtobufContentType, requests[0].Header.Get("Content-Type"))
+	
+	rl := decodeProto(t, decodeProto(t, bodies[0])[1][0].([]byte))
+	resourceAttr := decodeProto(t, decodeProto(t, rl[1][0].([]byte))[1][0].([]byte))
+	assert.Equal(t, "service.name", string(resourceAttr[1][0].([]byte)))
+	assert.Equal(t, "my-app", string(decodeProto(t, resourceAttr[2][0].([]byte))[1][0].([]byte)))
+	
+	record := decodeProto(t, decodeProto(t, rl[2][0].([]byte))[2][0].([]byte))
+	assert.Equal(t, uint64(17), record[2][0])
+	assert.Equal(t, "ERROR", string(record[3][0].([]byte)))
+	assert.Equal(t, "hello", string(decodeProto(t, record[5][0].([]byte))[1][0].([]byte)))
+	
+	attr := decodeProto(t, record[6][0].([]byte))
+	assert.Equal(t, "tenant", string(attr[1][0].([]byte)))
+	
+	require.NoError(t, closeFunc())
+}
+
+func TestTeeToOTLPPermanentError(t *testing.T) {
+	receiver := newOTLPReceiver(t)
+	receiver.failures.Store(10)
+	receiver.status = http.StatusBadRequest
+	
+	core, closeFunc, err := TeeToOTLP(zapcore.NewNopCore(), OTLPConfig{
+		Endpoint: receiver.URL,
+		Batch:    batch.Config{FlushInterval: time.Hour, InitialBackoff: time.Millisecond},
+	})
+	require.NoError(t, err)
+	defer closeFunc()
+	
+	logger := zap.New(core)
+	logger.Info("hello")
+	assert.ErrorContains(t, logger.Sync(), "400")
+	assert.Equal(t, int32(9), receiver.failures.Load(), "expected no retry")
+}
+
+func TestTeeToOTLPDropped(t *testing.T) {
+	receiver := newOTLPReceiver(t)
+	baseCore, logs := observer.New(zapcore.InfoLevel)
+	
+	core, closeFunc, err := TeeToOTLP(baseCore, OTLPConfig{
+		Endpoint: receiver.URL,
+		Batch:    batch.Config{MaxQueueSize: 1, FlushInterval: time.Hour, DropReportInterval: 10 * time.Millisecond},
+	})
+	require.NoError(t, err)
+	defer closeFunc()
+	
+	for i := 0; i < 3; i++ {
+		require.NoError(t, core.Write(zapcore.Entry{Level: zapcore.InfoLevel, Message: "hello"}, nil),
+			"expected the dropped records not failing the writes")
+	}
+	
+	require.Eventually(t, func() bool { return logs.FilterLevelExact(zapcore.WarnLevel).Len() == 1 }, time.Second, time.Millisecond)
+	summary := logs.FilterLevelExact(zapcore.WarnLevel).AllUntimed()[0]
+	assert.Equal(t, "the OTLP exporter dropped 2 messages", summary.Message)
+	assert.Equal(t, uint64(2), summary.ContextMap()[otlpDroppedKey])
+}
+
+func TestOTLPExtensionErrors(t *testing.T) {
+	_, _, err := OTLPExtension(OTLPConfig{Endpoint: "grpc://localhost:4317"})(zapcore.NewNopCore())
+	assert.Error(t, err)
+	
+	_, _, err = OTLPExtension(OTLPConfig{Endpoint: "://"})(zapcore.NewNopCore())
+	assert.Error(t, err)
+}
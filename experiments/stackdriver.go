@@ -2,10 +2,11 @@ package experiments
 
 import (
 	"fmt"
+	"math"
 	"time"
 	
 	"github.com/cockroachdb/errors"
-	"github.com/dapings/lager"
+	"github.com/dapings/lager/common"
 	"go.uber.org/zap/zapcore"
 )
 
@@ -13,11 +14,11 @@ type (
 	// StackdriverLogger A strace driver logger.
 	StackdriverLogger interface {
 		Flush() error
-		Log(entry loggingEntry)
+		Log(entry LoggingEntry)
 	}
 	
-	// loggingEntry a logging entry.
-	loggingEntry struct {
+	// LoggingEntry a logging entry.
+	LoggingEntry struct {
 		// Timestamp is the time of the entry. If zero, the current time is used.
 		Timestamp time.Time
 		
@@ -51,6 +52,7 @@ func (sdc *stackdriverCore) Enabled(l zapcore.Level) bool {
 // With impls zapcore.Core.
 func (sdc *stackdriverCore) With(fields []zapcore.Field) zapcore.Core {
 	return &stackdriverCore{
+		logger:       sdc.logger,
 		minimumLevel: sdc.minimumLevel,
 		fields:       clone(sdc.fields, fields),
 	}
@@ -69,10 +71,10 @@ func (sdc *stackdriverCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *za
 // writes a log entry to stackdriver.
 func (sdc *stackdriverCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
 	payload := clone(sdc.fields, fields)
-	payload[lager.GetLogPlaceholderLoggerName()] = e.LoggerName
-	payload[lager.GetLogPlaceholderMessage()] = e.Message
+	payload[common.LogPlaceholderLoggerName] = e.LoggerName
+	payload[common.LogPlaceholderMessage] = e.Message
 	
-	sdc.logger.Log(loggingEntry{
+	sdc.logger.Log(LoggingEntry{
 		Timestamp: e.Time,
 		Payload:   payload,
 	})
@@ -89,9 +91,9 @@ func (sdc *stackdriverCore) Sync() error {
 	return nil
 }
 
-// teeToStackdriver returns a zapcore.Core that writes the entries 
-// to the provided core and the Stackdriver core.
-func teeToStackdriver(baseCore zapcore.Core, logger StackdriverLogger) (zapcore.Core, lager.CloseFunc, error) {
+// TeeToStackdriver returns a zapcore.Core that writes the entries
+// to the provided core and the Stackdriver core, the returned common.CloseFunc flushes the logger.
+func TeeToStackdriver(baseCore zapcore.Core, logger StackdriverLogger) (zapcore.Core, common.CloseFunc, error) {
 	sdCore := &stackdriverCore{logger: logger}
 	for l := zapcore.DebugLevel; l <= zapcore.FatalLevel; l++ {
 		if baseCore.Enabled(l) {
@@ -100,7 +102,15 @@ func teeToStackdriver(baseCore zapcore.Core, logger StackdriverLogger) (zapcore.
 		}
 	}
 	
-	return zapcore.NewTee(baseCore, sdCore), func() error { return nil }, nil
+	return zapcore.NewTee(baseCore, sdCore), sdCore.Sync, nil
+}
+
+// StackdriverExtension returns the extension teeing the log to stackdriver,
+// which can be registered by the lager.Options WithExtension.
+func StackdriverExtension(logger StackdriverLogger) common.Extension {
+	return func(core zapcore.Core) (zapcore.Core, common.CloseFunc, error) {
+		return TeeToStackdriver(core, logger)
+	}
 }
 
 // clone copy a new filed map.
@@ -132,9 +142,9 @@ func clone(original map[string]interface{}, newFields []zapcore.Field) map[strin
 		case zapcore.Complex64Type, zapcore.Complex128Type:
 			clone[f.Key] = fmt.Sprint(f.Interface)
 		case zapcore.Float64Type:
-			clone[f.Key] = float64(f.Integer)
+			clone[f.Key] = math.Float64frombits(uint64(f.Integer))
 		case zapcore.Float32Type:
-			clone[f.Key] = float32(f.Integer)
+			clone[f.Key] = math.Float32frombits(uint32(f.Integer))
 		case zapcore.Int64Type:
 			clone[f.Key] = f.Integer
 		case zapcore.Int32Type:
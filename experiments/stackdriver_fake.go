@@ -0,0 +1,119 @@
+package experiments
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// FakeStackdriverLogger an in-memory StackdriverLogger, which records the logged entries
+	// instead of sending them to a Logging API, mainly useful for tests and local development.
+	//
+	// like a real client, the logged entries are pending until flushed,
+	// the flush can be configured to fail or to be slow.
+	FakeStackdriverLogger struct {
+		mu           sync.Mutex
+		pending      []LoggingEntry
+		flushed      []LoggingEntry
+		flushErr     error
+		flushLatency time.Duration
+		flushCount   int
+	}
+)
+
+// NewFakeStackdriverLogger returns an empty FakeStackdriverLogger.
+func NewFakeStackdriverLogger() *FakeStackdriverLogger {
+	return &FakeStackdriverLogger{}
+}
+
+// Log impls StackdriverLogger.
+func (f *FakeStackdriverLogger) Log(entry LoggingEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	
+	f.pending = append(f.pending, entry)
+}
+
+// Flush impls StackdriverLogger,
+// it waits for the flush latency, then either fails with the flush error or flushes the pending entries.
+func (f *FakeStackdriverLogger) Flush() error {
+	f.mu.Lock()
+	latency := f.flushLatency
+	f.mu.Unlock()
+	
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	
+	f.flushCount++
+	if f.flushErr != nil {
+		return f.flushErr
+	}
+	
+	f.flushed = append(f.flushed, f.pending...)
+	f.pending = nil
+	
+	return nil
+}
+
+// SetFlushError sets the error returned by the subsequent flushes, nil to flush successfully again.
+func (f *FakeStackdriverLogger) SetFlushError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	
+	f.flushErr = err
+}
+
+// SetFlushLatency sets the duration each subsequent flush takes.
+func (f *FakeStackdriverLogger) SetFlushLatency(latency time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	
+	f.flushLatency = latency
+}
+
+// Entries returns a copy of all the logged entries, either flushed or pending, in the logged order.
+func (f *FakeStackdriverLogger) Entries() []LoggingEntry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	
+	entries := make([]LoggingEntry, 0, len(f.flushed)+len(f.pending))
+	entries = append(entries, f.flushed...)
+	return append(entries, f.pending...)
+}
+
+// FlushedEntries returns a copy of the successfully flushed entries.
+func (f *FakeStackdriverLogger) FlushedEntries() []LoggingEntry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	
+	return append([]LoggingEntry(nil), f.flushed...)
+}
+
+// PendingEntries returns a copy of the entries not flushed yet.
+func (f *FakeStackdriverLogger) PendingEntries() []LoggingEntry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	
+	return append([]LoggingEntry(nil), f.pending...)
+}
+
+// FlushCount returns the number of attempted flushes, including the failed ones.
+func (f *FakeStackdriverLogger) FlushCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	
+	return f.flushCount
+}
+
+// Reset drops all the recorded entries and flushes, and restores the successful and immediate flush.
+func (f *FakeStackdriverLogger) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	
+	f.pending, f.flushed = nil, nil
+	f.flushErr, f.flushLatency, f.flushCount = nil, 0, 0
+}
@@ -0,0 +1,86 @@
+package experiments
+
+import (
+	"testing"
+	"time"
+	
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var _ StackdriverLogger = (*FakeStackdriverLogger)(nil)
+
+func TestTeeToStackdriver(t *testing.T) {
+	baseCore, observed := observer.New(zapcore.InfoLevel)
+	fake := NewFakeStackdriverLogger()
+	
+	core, closeFunc, err := TeeToStackdriver(baseCore, fake)
+	require.NoError(t, err)
+	
+	logger := zap.New(core).Named("ads").With(zap.String("tenant", "foo"))
+	logger.Debug("not enabled")
+	logger.Info("hello", zap.Int("count", 3), zap.Float64("ratio", 0.5), zap.Error(errors.New("boom")))
+	
+	assert.Equal(t, 1, observed.Len())
+	assert.Empty(t, fake.FlushedEntries())
+	require.Len(t, fake.PendingEntries(), 1)
+	
+	require.NoError(t, closeFunc())
+	entries := fake.FlushedEntries()
+	require.Len(t, entries, 1)
+	assert.Equal(t, map[string]interface{}{
+		"@logger":  "ads",
+		"@message": "hello",
+		"tenant":   "foo",
+		"count":    int64(3),
+		"ratio":    0.5,
+		"error":    "boom",
+	}, entries[0].Payload)
+	assert.Empty(t, fake.PendingEntries())
+}
+
+func TestFakeStackdriverLoggerFlushError(t *testing.T) {
+	fake := NewFakeStackdriverLogger()
+	core, _, err := TeeToStackdriver(zapcore.NewNopCore(), fake)
+	require.NoError(t, err)
+	
+	logger := zap.New(core)
+	logger.Info("hello")
+	
+	fake.SetFlushError(errors.New("unavailable"))
+	assert.ErrorContains(t, logger.Sync(), "unavailable")
+	assert.Len(t, fake.PendingEntries(), 1)
+	
+	fake.SetFlushError(nil)
+	assert.NoError(t, logger.Sync())
+	assert.Len(t, fake.FlushedEntries(), 1)
+	assert.Len(t, fake.Entries(), 1)
+	assert.Equal(t, 2, fake.FlushCount())
+	
+	fake.Reset()
+	assert.Empty(t, fake.Entries())
+	assert.Zero(t, fake.FlushCount())
+}
+
+func TestFakeStackdriverLoggerFlushLatency(t *testing.T) {
+	fake := NewFakeStackdriverLogger()
+	fake.SetFlushLatency(20 * time.Millisecond)
+	
+	start := time.Now()
+	assert.NoError(t, fake.Flush())
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestStackdriverExtension(t *testing.T) {
+	fake := NewFakeStackdriverLogger()
+	core, closeFunc, err := StackdriverExtension(fake)(zapcore.NewNopCore())
+	require.NoError(t, err)
+	
+	zap.New(core).Info("hello")
+	require.NoError(t, closeFunc())
+	assert.Len(t, fake.FlushedEntries(), 1)
+}
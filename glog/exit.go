@@ -0,0 +1,107 @@
+package glog
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// the exit codes of the Fatal and the Exit.
+	fatalExitCode = 255
+	exitExitCode  = 1
+	
+	// goroutinesKey the key of the field carrying the stacks of all goroutines.
+	goroutinesKey = "goroutines"
+)
+
+type (
+	// exitHook the zapcore.CheckWriteHook exiting the process after the fatal entry is written.
+	exitHook int
+)
+
+var (
+	exitMu    sync.Mutex
+	exitFunc  = os.Exit
+	exitHooks []func()
+	
+	// dumpStacks whether the Fatal dumps the stacks of all goroutines, like the glog.
+	dumpStacks = atomic.NewBool(true)
+)
+
+// SetExitFunc replaces the function exiting the process after the Fatal and the Exit, which is the os.Exit by default,
+// e.g. to test a fatal path, and returns a function restoring the previous one.
+func SetExitFunc(fn func(code int)) func() {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	
+	prev := exitFunc
+	exitFunc = fn
+	
+	return func() {
+		exitMu.Lock()
+		defer exitMu.Unlock()
+		
+		exitFunc = prev
+	}
+}
+
+// RegisterExitHook registers a hook run by the Fatal and the Exit before the log is flushed and the process exits,
+// e.g. to shut down a server or close the async sinks, the hooks are run in the reverse order of the registration.
+func RegisterExitHook(hook func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	
+	exitHooks = append(exitHooks, hook)
+}
+
+// SetFatalStackDump sets whether the Fatal dumps the stacks of all goroutines within the fatal entry, true by default.
+func SetFatalStackDump(enabled bool) {
+	dumpStacks.Store(enabled)
+}
+
+// logFatal writes the message at the fatal level, then runs the exit hooks, flushes the log and exits with the code.
+// the stacks of all goroutines are added when the stacks is true and the stack dump is enabled.
+func logFatal(depth int, code int, stacks bool, msg string) {
+	var fields []zapcore.Field
+	if stacks && dumpStacks.Load() {
+		fields = append(fields, zap.String(goroutinesKey, allStacks()))
+	}
+	
+	// zap runs the exit hook even though the fatal level is disabled.
+	logger := zap.L().WithOptions(zap.AddCallerSkip(depth+1), zap.WithFatalHook(exitHook(code)))
+	if ce := logger.Check(zapcore.FatalLevel, msg); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// OnWrite impls zapcore.CheckWriteHook.
+func (h exitHook) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {
+	exitMu.Lock()
+	hooks := append([]func(){}, exitHooks...)
+	exit := exitFunc
+	exitMu.Unlock()
+	
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+	
+	Flush()
+	exit(int(h))
+}
+
+// allStacks returns the stacks of all goroutines.
+func allStacks() string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
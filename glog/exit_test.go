@@ -0,0 +1,72 @@
+package glog
+
+import (
+	"runtime"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// captureExit replaces the exit function, and returns the exit codes.
+func captureExit(t *testing.T) *[]int {
+	var codes []int
+	t.Cleanup(SetExitFunc(func(code int) { codes = append(codes, code) }))
+	
+	return &codes
+}
+
+func TestFatal(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	t.Cleanup(zap.ReplaceGlobals(zap.New(core, zap.AddCaller())))
+	codes := captureExit(t)
+	
+	var order []string
+	t.Cleanup(func() { exitHooks = nil })
+	RegisterExitHook(func() { order = append(order, "first") })
+	RegisterExitHook(func() { order = append(order, "second") })
+	
+	_, file, line, _ := runtime.Caller(0)
+	Fatalf("fatal %d", 1)
+	Exit("exit")
+	
+	assert.Equal(t, []int{fatalExitCode, exitExitCode}, *codes)
+	assert.Equal(t, []string{"second", "first", "second", "first"}, order)
+	
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 2)
+	assert.Equal(t, zapcore.FatalLevel, entries[0].Level)
+	assert.Equal(t, "fatal 1", entries[0].Message)
+	assert.Equal(t, file, entries[0].Caller.File)
+	assert.Equal(t, line+1, entries[0].Caller.Line)
+	assert.Contains(t, entries[0].ContextMap()[goroutinesKey], "goroutine ")
+	
+	assert.Equal(t, zapcore.FatalLevel, entries[1].Level)
+	assert.Equal(t, line+2, entries[1].Caller.Line)
+	assert.NotContains(t, entries[1].ContextMap(), goroutinesKey)
+}
+
+func TestFatalWithoutStackDump(t *testing.T) {
+	logs := observe(t, zapcore.InfoLevel)
+	codes := captureExit(t)
+	t.Cleanup(func() { SetFatalStackDump(true) })
+	SetFatalStackDump(false)
+	
+	FatalDepth(0, "fatal")
+	
+	assert.Equal(t, []int{fatalExitCode}, *codes)
+	require.Equal(t, 1, logs.Len())
+	assert.NotContains(t, logs.All()[0].ContextMap(), goroutinesKey)
+}
+
+func TestFatalDisabled(t *testing.T) {
+	observe(t, zapcore.FatalLevel+1)
+	codes := captureExit(t)
+	
+	Exitln("exit")
+	
+	assert.Equal(t, []int{exitExitCode}, *codes, "expected to exit even though the fatal level is disabled")
+}
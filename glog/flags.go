@@ -0,0 +1,133 @@
+package glog
+
+import (
+	"flag"
+	"log"
+	"strconv"
+	"strings"
+	
+	"github.com/cockroachdb/errors"
+	"go.uber.org/atomic"
+	"go.uber.org/zap/zapcore"
+)
+
+type (
+	// verbosityFlag the flag.Value of the -v.
+	verbosityFlag struct{}
+	
+	// vmoduleFlag the flag.Value of the -vmodule.
+	vmoduleFlag struct{}
+	
+	// boolFlag the flag.Value of an atomic bool.
+	boolFlag struct {
+		*atomic.Bool
+	}
+	
+	// stdLogWriter writes the output of the standard log package at a severity.
+	stdLogWriter struct {
+		level zapcore.Level
+	}
+)
+
+var (
+	// logToStderr the -logtostderr, which is accepted for the compatibility only,
+	// since the destination of the log is configured by the lager options.
+	logToStderr = atomic.NewBool(false)
+	
+	// severityToLevel maps the glog severity names to the levels.
+	severityToLevel = map[string]zapcore.Level{
+		"INFO":    zapcore.InfoLevel,
+		"WARNING": zapcore.WarnLevel,
+		"ERROR":   zapcore.ErrorLevel,
+		"FATAL":   zapcore.FatalLevel,
+	}
+)
+
+// InitFlags registers the glog flags -v, -vmodule and -logtostderr to the flag set,
+// which is the flag.CommandLine when nil, like the klog.InitFlags, e.g.
+//
+//	glog.InitFlags(nil)
+//	flag.Parse()
+func InitFlags(fs *flag.FlagSet) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	
+	fs.Var(verbosityFlag{}, "v", "log level for V logs")
+	fs.Var(vmoduleFlag{}, "vmodule", "comma-separated list of pattern=N settings for file-filtered logging")
+	fs.Var(boolFlag{logToStderr}, "logtostderr", "log to standard error instead of files, the lager output paths win")
+}
+
+// String impls flag.Value.
+func (verbosityFlag) String() string {
+	return strconv.Itoa(int(GetVerbosity()))
+}
+
+// Set impls flag.Value.
+func (verbosityFlag) Set(s string) error {
+	v, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return errors.Wrapf(err, "invalid verbosity level: %q", s)
+	}
+	
+	SetVerbosity(Level(v))
+	return nil
+}
+
+// String impls flag.Value.
+func (vmoduleFlag) String() string {
+	if state, _ := vmodule.Load().(*vmoduleState); state != nil {
+		return state.spec
+	}
+	
+	return ""
+}
+
+// Set impls flag.Value.
+func (vmoduleFlag) Set(s string) error {
+	return SetVModule(s)
+}
+
+// Set impls flag.Value.
+func (f boolFlag) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	
+	f.Store(v)
+	return nil
+}
+
+// IsBoolFlag allows the flag without value, e.g. -logtostderr.
+func (boolFlag) IsBoolFlag() bool {
+	return true
+}
+
+// CopyStandardLogTo arranges for the messages written to the standard log package
+// to be logged at the named severity: INFO, WARNING, ERROR or FATAL, like the glog,
+// the flags of the standard logger are cleared, since the time and the caller are annotated by lager.
+// it panics for an invalid severity name.
+func CopyStandardLogTo(name string) {
+	level, ok := severityToLevel[strings.ToUpper(name)]
+	if !ok {
+		panic(errors.Errorf("glog.CopyStandardLogTo(%q): unrecognized severity name", name))
+	}
+	
+	log.SetFlags(0)
+	log.SetOutput(stdLogWriter{level: level})
+}
+
+// Write impls io.Writer, the caller is the call site of the standard log functions, e.g. log.Printf.
+func (w stdLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	
+	// the callers are the log.Output, the log function, and the call site.
+	if w.level == zapcore.FatalLevel {
+		logFatal(3, fatalExitCode, true, msg)
+	} else {
+		logDepth(3, w.level, msg)
+	}
+	
+	return len(p), nil
+}
@@ -0,0 +1,72 @@
+package glog
+
+import (
+	"flag"
+	"log"
+	"os"
+	"runtime"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestInitFlags(t *testing.T) {
+	t.Cleanup(func() {
+		SetVerbosity(0)
+		_ = SetVModule("")
+		logToStderr.Store(false)
+	})
+	
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	InitFlags(fs)
+	
+	require.NoError(t, fs.Parse([]string{"-v=3", "-vmodule=flags_test=5", "-logtostderr"}))
+	assert.Equal(t, Level(3), GetVerbosity())
+	assert.Equal(t, "flags_test=5", fs.Lookup("vmodule").Value.String())
+	assert.Equal(t, "3", fs.Lookup("v").Value.String())
+	assert.True(t, logToStderr.Load())
+	
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&nopWriter{})
+	InitFlags(fs)
+	assert.Error(t, fs.Parse([]string{"-v=foo"}))
+	assert.Error(t, fs.Parse([]string{"-vmodule=foo"}))
+}
+
+func TestCopyStandardLogTo(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	t.Cleanup(zap.ReplaceGlobals(zap.New(core, zap.AddCaller())))
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	})
+	
+	CopyStandardLogTo("WARNING")
+	_, file, line, _ := runtime.Caller(0)
+	log.Printf("hello %s", "world")
+	
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+	assert.Equal(t, "hello world", entries[0].Message)
+	assert.Equal(t, file, entries[0].Caller.File)
+	assert.Equal(t, line+1, entries[0].Caller.Line)
+	
+	codes := captureExit(t)
+	CopyStandardLogTo("fatal")
+	log.Print("fatal")
+	assert.Equal(t, []int{fatalExitCode}, *codes)
+	
+	assert.Panics(t, func() { CopyStandardLogTo("TRACE") })
+}
+
+// nopWriter discards the usage of the failed flag parsing.
+type nopWriter struct{}
+
+func (*nopWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
@@ -2,124 +2,339 @@ package glog
 
 import (
 	"fmt"
-	"os"
+	"math"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	
+	"github.com/cockroachdb/errors"
+	"github.com/dapings/lager/common"
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // Package glog exposes an API subset of the [glog](https://github.com/golang/glog) package.
 // All logging state delivered to this package is shunted to the global [zap logger](https://github.com/uber-go/zap).
 
 type (
+	// Level the verbosity level of the V, which is mapped onto the lager levels:
+	// V(0) logs at the info level, and V(1) and above log at the debug level.
 	Level int32
 	
-	Verbose bool
+	// Verbose reports whether a verbosity level is enabled, and logs at the lager level of it, returned by the V, e.g.
+	//
+	//	if glog.V(2).Enabled() {
+	//		glog.Info(dump())
+	//	}
+	Verbose struct {
+		enabled bool
+		level   zapcore.Level
+	}
+	
+	// vmoduleFilter a pattern of the vmodule and its verbosity level.
+	vmoduleFilter struct {
+		pattern string
+		// the number of the trailing path elements matched against the pattern.
+		elems int
+		level Level
+	}
+	
+	// vmoduleState the vmodule filters and the cache of the verbosity level per call site.
+	vmoduleState struct {
+		spec    string
+		filters []vmoduleFilter
+		// the verbosity level of the matched pattern, or the vmoduleNoMatch, per call site.
+		levels sync.Map // map[uintptr]Level
+	}
+)
+
+const (
+	// vmoduleNoMatch the cached level of a call site matched by none of the vmodule patterns.
+	vmoduleNoMatch = Level(math.MinInt32)
+)
+
+var (
+	// verbosity the global verbosity level, the -v of the glog.
+	verbosity = atomic.NewInt32(0)
+	
+	// vmodule the *vmoduleState of the per-file verbosity levels, the -vmodule of the glog.
+	vmodule atomic.Value
 )
 
 func Flush() {
 	_ = zap.L().Sync()
 }
 
-func V(Level) Verbose {
-	return Verbose(zap.L().Core().Enabled(zap.DebugLevel))
+// SetVerbosity sets the global verbosity level, like the -v flag of the glog.
+func SetVerbosity(level Level) {
+	verbosity.Store(int32(level))
+}
+
+// GetVerbosity returns the global verbosity level.
+func GetVerbosity() Level {
+	return Level(verbosity.Load())
+}
+
+// SetVModule sets the per-file verbosity levels, like the -vmodule flag of the glog,
+// which is a comma-separated list of pattern=N, e.g. "gopher*=3,pkg/file=2".
+// the pattern is a path.Match pattern matched against the base name of the source file without the .go suffix,
+// or against as many trailing elements of the path as the pattern has when it contains a slash, e.g. "pkg/file".
+// the first matched pattern wins over the global verbosity level, an empty spec resets the patterns.
+func SetVModule(spec string) error {
+	state := &vmoduleState{spec: spec}
+	for _, pat := range strings.Split(spec, ",") {
+		if pat = strings.TrimSpace(pat); pat == "" {
+			continue
+		}
+		
+		pattern, v, ok := strings.Cut(pat, "=")
+		if !ok || pattern == "" {
+			return errors.Errorf("invalid vmodule pattern: %q", pat)
+		}
+		
+		level, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return errors.Wrapf(err, "invalid vmodule level: %q", pat)
+		}
+		
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid vmodule pattern: %q", pat)
+		}
+		
+		state.filters = append(state.filters, vmoduleFilter{
+			pattern: strings.TrimSuffix(pattern, ".go"),
+			elems:   strings.Count(pattern, "/") + 1,
+			level:   Level(level),
+		})
+	}
+	
+	vmodule.Store(state)
+	return nil
+}
+
+// levelOf returns the lager (zap) level of the verbosity level.
+func levelOf(level Level) zapcore.Level {
+	if level <= 0 {
+		return zapcore.InfoLevel
+	}
+	
+	return zapcore.DebugLevel
+}
+
+// V reports whether the verbosity at the call site is at least the requested level,
+// and the lager level mapped from the requested level is enabled, e.g.
+//
+//	glog.V(2).Infof("log this %d", 1)
+func V(level Level) Verbose {
+	return VDepth(1, level)
+}
+
+// VDepth acts as the V but uses the depth to determine the call site, VDepth(0, level) is the V(level).
+func VDepth(depth int, level Level) Verbose {
+	l := levelOf(level)
+	return Verbose{enabled: verbosityAt(depth+1, level) && zap.L().Core().Enabled(l), level: l}
+}
+
+// verbosityAt reports whether the verbosity of the call site at the depth is at least the level.
+func verbosityAt(depth int, level Level) bool {
+	if Level(verbosity.Load()) >= level {
+		return true
+	}
+	
+	state, _ := vmodule.Load().(*vmoduleState)
+	if state == nil || len(state.filters) == 0 {
+		return false
+	}
+	
+	pc, _, _, ok := runtime.Caller(depth + 1)
+	if !ok {
+		return false
+	}
+	
+	v, ok := state.levels.Load(pc)
+	if !ok {
+		v = state.match(pc)
+		state.levels.Store(pc, v)
+	}
+	
+	return v.(Level) != vmoduleNoMatch && v.(Level) >= level
+}
+
+// match returns the verbosity level of the first pattern matching the source file of the pc,
+// or the vmoduleNoMatch if none.
+func (state *vmoduleState) match(pc uintptr) Level {
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return vmoduleNoMatch
+	}
+	
+	file, _ := fn.FileLine(pc)
+	elems := strings.Split(strings.TrimSuffix(filepath.ToSlash(file), ".go"), "/")
+	for _, f := range state.filters {
+		name := elems
+		if len(name) > f.elems {
+			name = name[len(name)-f.elems:]
+		}
+		
+		if matched, _ := path.Match(f.pattern, strings.Join(name, "/")); matched {
+			return f.level
+		}
+	}
+	
+	return vmoduleNoMatch
+}
+
+// logDepth writes the message and the fields at the level to the global zap logger,
+// whose caller is the call site at the depth above the caller of the logDepth.
+func logDepth(depth int, level zapcore.Level, msg string, fields ...zapcore.Field) {
+	logger := zap.L().WithOptions(zap.AddCallerSkip(depth + 1))
+	if ce := logger.Check(level, msg); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// Enabled reports whether the verbosity level is enabled, which guards the costly arguments, e.g.
+//
+//	if glog.V(2).Enabled() {
+//		glog.V(2).Info(dump())
+//	}
+func (v Verbose) Enabled() bool {
+	return v.enabled
 }
 
 func (v Verbose) Info(args ...interface{}) {
-	zap.S().Debug(args...)
+	if v.enabled {
+		logDepth(1, v.level, fmt.Sprint(args...))
+	}
+}
+
+func (v Verbose) InfoDepth(depth int, args ...interface{}) {
+	if v.enabled {
+		logDepth(depth+1, v.level, fmt.Sprint(args...))
+	}
 }
 
 func (v Verbose) Infoln(args ...interface{}) {
-	zap.S().Debug(fmt.Sprint(args...), '\n')
+	if v.enabled {
+		logDepth(1, v.level, common.Sprintln(args...))
+	}
 }
 
 func (v Verbose) Infof(format string, args ...interface{}) {
-	zap.S().Debugf(format, args...)
+	if v.enabled {
+		logDepth(1, v.level, fmt.Sprintf(format, args...))
+	}
+}
+
+// InfoS logs the message with the alternating keys and values as the fields, like the klog.
+func (v Verbose) InfoS(msg string, keysAndValues ...interface{}) {
+	if v.enabled {
+		logDepth(1, v.level, msg, common.KVToFields(nil, keysAndValues)...)
+	}
+}
+
+// ErrorS logs the error and the message with the alternating keys and values as the fields at the error level,
+// like the klog.
+func (v Verbose) ErrorS(err error, msg string, keysAndValues ...interface{}) {
+	if v.enabled {
+		logDepth(1, zapcore.ErrorLevel, msg, common.KVToFields(err, keysAndValues)...)
+	}
 }
 
 func Info(args ...interface{}) {
-	zap.S().Info(args...)
+	logDepth(1, zapcore.InfoLevel, fmt.Sprint(args...))
 }
 
 func InfoDepth(depth int, args ...interface{}) {
-	zap.S().Info(args...)
+	logDepth(depth+1, zapcore.InfoLevel, fmt.Sprint(args...))
 }
 
 func Infoln(args ...interface{}) {
-	zap.S().Info(fmt.Sprint(args...), '\n')
+	logDepth(1, zapcore.InfoLevel, common.Sprintln(args...))
 }
 
 func Infof(format string, args ...interface{}) {
-	zap.S().Infof(format, args...)
+	logDepth(1, zapcore.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+// InfoS logs the message with the alternating keys and values as the fields, like the klog, e.g.
+//
+//	glog.InfoS("Pod status updated", "pod", "kube-dns", "status", "ready")
+func InfoS(msg string, keysAndValues ...interface{}) {
+	logDepth(1, zapcore.InfoLevel, msg, common.KVToFields(nil, keysAndValues)...)
 }
 
 func Warning(args ...interface{}) {
-	zap.S().Warn(args...)
+	logDepth(1, zapcore.WarnLevel, fmt.Sprint(args...))
 }
 
 func WarningDepth(depth int, args ...interface{}) {
-	zap.S().Warn(args...)
+	logDepth(depth+1, zapcore.WarnLevel, fmt.Sprint(args...))
 }
 
 func Warningln(args ...interface{}) {
-	zap.S().Warn(fmt.Sprint(args...), '\n')
+	logDepth(1, zapcore.WarnLevel, common.Sprintln(args...))
 }
 
 func Warningf(format string, args ...interface{}) {
-	zap.S().Warnf(format, args...)
+	logDepth(1, zapcore.WarnLevel, fmt.Sprintf(format, args...))
 }
 
 func Error(args ...interface{}) {
-	zap.S().Error(args...)
+	logDepth(1, zapcore.ErrorLevel, fmt.Sprint(args...))
 }
 
 func ErrorDepth(depth int, args ...interface{}) {
-	zap.S().Error(args...)
+	logDepth(depth+1, zapcore.ErrorLevel, fmt.Sprint(args...))
 }
 
 func Errorln(args ...interface{}) {
-	zap.S().Error(fmt.Sprint(args...), '\n')
+	logDepth(1, zapcore.ErrorLevel, common.Sprintln(args...))
 }
 
 func Errorf(format string, args ...interface{}) {
-	zap.S().Errorf(format, args...)
+	logDepth(1, zapcore.ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+// ErrorS logs the error and the message with the alternating keys and values as the fields, like the klog, e.g.
+//
+//	glog.ErrorS(err, "Failed to update pod status", "pod", "kube-dns")
+func ErrorS(err error, msg string, keysAndValues ...interface{}) {
+	logDepth(1, zapcore.ErrorLevel, msg, common.KVToFields(err, keysAndValues)...)
 }
 
 func Fatal(args ...interface{}) {
-	zap.S().Error(args...)
-	os.Exit(255)
+	logFatal(1, fatalExitCode, true, fmt.Sprint(args...))
 }
 
 func FatalDepth(depth int, args ...interface{}) {
-	zap.S().Error(args...)
-	os.Exit(255)
+	logFatal(depth+1, fatalExitCode, true, fmt.Sprint(args...))
 }
 
 func Fatalln(args ...interface{}) {
-	zap.S().Error(fmt.Sprint(args...), '\n')
-	os.Exit(255)
+	logFatal(1, fatalExitCode, true, common.Sprintln(args...))
 }
 
 func Fatalf(format string, args ...interface{}) {
-	zap.S().Errorf(format, args...)
-	os.Exit(255)
+	logFatal(1, fatalExitCode, true, fmt.Sprintf(format, args...))
 }
 
 func Exit(args ...interface{}) {
-	zap.S().Error(args...)
-	os.Exit(1)
+	logFatal(1, exitExitCode, false, fmt.Sprint(args...))
 }
 
 func ExitDepth(depth int, args ...interface{}) {
-	zap.S().Error(fmt.Sprint(args...), '\n')
-	os.Exit(1)
+	logFatal(depth+1, exitExitCode, false, fmt.Sprint(args...))
 }
 
 func Exitln(args ...interface{}) {
-	zap.S().Error(fmt.Sprint(args...), '\n')
-	os.Exit(1)
+	logFatal(1, exitExitCode, false, common.Sprintln(args...))
 }
 
 func Exitf(format string, args ...interface{}) {
-	zap.S().Errorf(format, args...)
-	os.Exit(1)
+	logFatal(1, exitExitCode, false, fmt.Sprintf(format, args...))
 }
@@ -1,7 +1,16 @@
 package glog
 
 import (
+	"runtime"
 	"testing"
+	
+	"github.com/cockroachdb/errors"
+	"github.com/dapings/lager/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestAll(t *testing.T) {
@@ -28,3 +37,155 @@ func TestAll(t *testing.T) {
 	
 	Flush()
 }
+
+// observe replaces the global zap logger by an observer at the level, and restores it by the cleanup.
+func observe(t *testing.T, level zapcore.Level) *observer.ObservedLogs {
+	core, logs := observer.New(level)
+	t.Cleanup(zap.ReplaceGlobals(zap.New(core)))
+	
+	return logs
+}
+
+func TestV(t *testing.T) {
+	logs := observe(t, zapcore.DebugLevel)
+	t.Cleanup(func() { SetVerbosity(0) })
+	
+	assert.True(t, V(0).Enabled())
+	assert.False(t, V(1).Enabled())
+	
+	V(0).Info("v0")
+	if V(0).Enabled() {
+		Info("guarded")
+	}
+	
+	SetVerbosity(2)
+	for i := 1; i < 5; i++ {
+		V(Level(i)).Infof("v%d", i)
+	}
+	V(0).Info("v0 verbose")
+	
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 5)
+	assert.Equal(t, zapcore.InfoLevel, entries[0].Level)
+	assert.Equal(t, "v0", entries[0].Message)
+	assert.Equal(t, "guarded", entries[1].Message)
+	assert.Equal(t, zapcore.DebugLevel, entries[2].Level)
+	assert.Equal(t, "v2", entries[3].Message)
+	assert.Equal(t, zapcore.InfoLevel, entries[4].Level, "expected the V(0) at the info level regardless of the verbosity")
+	
+	// the debug level of the V(1) and above is disabled by the lager level.
+	observe(t, zapcore.InfoLevel)
+	assert.True(t, V(0).Enabled())
+	assert.False(t, V(1).Enabled())
+}
+
+func TestVModule(t *testing.T) {
+	observe(t, zapcore.DebugLevel)
+	t.Cleanup(func() { _ = SetVModule("") })
+	
+	require.NoError(t, SetVModule("glog_test=3, other*=5"))
+	assert.True(t, V(3).Enabled())
+	assert.False(t, V(4).Enabled())
+	assert.True(t, VDepth(0, 3).Enabled())
+	
+	// the call site of the depth 1 is the testing package.
+	assert.False(t, VDepth(1, 1).Enabled())
+	
+	require.NoError(t, SetVModule("glog/glog_te?t=1"))
+	assert.True(t, V(1).Enabled())
+	assert.False(t, V(2).Enabled())
+	
+	require.NoError(t, SetVModule(""))
+	assert.False(t, V(1).Enabled())
+	
+	// the call site matched by none of the patterns follows the global verbosity level.
+	require.NoError(t, SetVModule("other=5"))
+	t.Cleanup(func() { SetVerbosity(0) })
+	for _, c := range []struct{ verbosity, level Level }{{2, 3}, {0, 2}, {1, 1}, {0, 1}} {
+		SetVerbosity(c.verbosity)
+		assert.Equal(t, c.verbosity >= c.level, V(c.level).Enabled(), "V(%d) at verbosity %d", c.level, c.verbosity)
+	}
+	
+	for _, spec := range []string{"glog_test", "=1", "glog_test=foo", "[=1"} {
+		assert.Error(t, SetVModule(spec), "expected to fail to parse %q", spec)
+	}
+}
+
+// logFromHelper logs by the *Depth functions on behalf of its caller.
+func logFromHelper() {
+	InfoDepth(1, "info")
+	WarningDepth(1, "warning")
+	ErrorDepth(1, "error")
+}
+
+func TestCaller(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	t.Cleanup(zap.ReplaceGlobals(zap.New(core, zap.AddCaller())))
+	t.Cleanup(func() { SetVerbosity(0) })
+	SetVerbosity(1)
+	
+	_, file, line, _ := runtime.Caller(0)
+	Info("info")
+	Infof("info")
+	Infoln("info")
+	Warning("warning")
+	Warningf("warning")
+	Warningln("warning")
+	Error("error")
+	Errorf("error")
+	Errorln("error")
+	V(1).Info("verbose")
+	V(1).Infof("verbose")
+	V(1).Infoln("verbose")
+	logFromHelper()
+	
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 15)
+	for i, e := range entries {
+		want := line + 1 + i
+		if i >= 12 {
+			want = line + 13
+		}
+		
+		assert.Equal(t, file, e.Caller.File, e.Message)
+		assert.Equal(t, want, e.Caller.Line, e.Message)
+	}
+}
+
+func TestStructured(t *testing.T) {
+	logs := observe(t, zapcore.DebugLevel)
+	t.Cleanup(func() { SetVerbosity(0) })
+	SetVerbosity(1)
+	
+	InfoS("pod updated", "pod", "kube-dns", "count", 2)
+	ErrorS(errors.New("boom"), "failed", 3, "three", "missing")
+	V(1).InfoS("verbose", "key", "value")
+	V(2).InfoS("disabled")
+	V(1).ErrorS(nil, "verbose error")
+	
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 4)
+	assert.Equal(t, zapcore.InfoLevel, entries[0].Level)
+	assert.Equal(t, map[string]interface{}{"pod": "kube-dns", "count": int64(2)}, entries[0].ContextMap())
+	assert.Equal(t, zapcore.ErrorLevel, entries[1].Level)
+	assert.Equal(t, "boom", entries[1].ContextMap()["error"])
+	assert.Equal(t, "three", entries[1].ContextMap()["3"])
+	assert.Equal(t, common.MissingValue, entries[1].ContextMap()["missing"])
+	assert.Equal(t, zapcore.DebugLevel, entries[2].Level)
+	assert.Equal(t, map[string]interface{}{"key": "value"}, entries[2].ContextMap())
+	assert.Equal(t, zapcore.ErrorLevel, entries[3].Level)
+}
+
+func TestLn(t *testing.T) {
+	logs := observe(t, zapcore.DebugLevel)
+	
+	Infoln("foo", "bar", 1)
+	V(0).Infoln("foo", 1, 2)
+	
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "foo bar 1", entries[0].Message)
+	assert.Equal(t, "foo 1 2", entries[1].Message)
+	assert.True(t, V(0).Enabled())
+	assert.False(t, V(1).Enabled())
+}
@@ -0,0 +1,154 @@
+package lager
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dapings/lager/common"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc/grpclog"
+)
+
+type (
+	// grpcLogger the grpclog.LoggerV2 routing the gRPC logs into the @grpc scope,
+	// which writes through the scope, so it's safe to be held by the gRPC stack after the Configure or the shutdown.
+	grpcLogger struct {
+		scope *Scope
+		// the copies of the scope skipping the callers, map[int]*Scope keyed by the skip.
+		skipped sync.Map
+	}
+)
+
+var (
+	_ grpclog.DepthLoggerV2 = (*grpcLogger)(nil)
+
+	grpcScope = RegisterScope(GrpcScopeName, "logs from gRPC")
+
+	// the grpclog.SetLoggerV2 isn't guarded against the gRPC reading the logger, so the logger is set once,
+	// and the LogGrpc of the latest Configure enables or disables it.
+	grpcLoggerOnce sync.Once
+	grpcEnabled    = atomic.NewBool(false)
+)
+
+// configureGrpc routes the gRPC logs into the @grpc scope when the LogGrpc is set.
+// once the logger is set, a later Configure without the LogGrpc discards the gRPC logs,
+// since the logger of the gRPC can't be restored safely.
+func configureGrpc(options *Options) {
+	grpcEnabled.Store(options.LogGrpc)
+	if options.LogGrpc {
+		grpcLoggerOnce.Do(func() {
+			grpclog.SetLoggerV2(&grpcLogger{scope: grpcScope})
+		})
+	}
+}
+
+// log writes the message to the scope, the skip is the number of the callers above the methods of the logger,
+// e.g. the grpclog.Info. the fatal level is always written, so that the process exits like the gRPC expects,
+// even though the logger is disabled.
+func (l *grpcLogger) log(skip int, level Level, msg string) {
+	if level == FatalLevel || (grpcEnabled.Load() && l.scope.Enabled(level)) {
+		l.skippedScope(skip+2).Log(level, msg)
+	}
+}
+
+// skippedScope returns the copy of the scope skipping the callers, which is built once per skip,
+// so that the copy keeps its zap logger across the calls.
+func (l *grpcLogger) skippedScope(skip int) *Scope {
+	if s, ok := l.skipped.Load(skip); ok {
+		return s.(*Scope)
+	}
+
+	s, _ := l.skipped.LoadOrStore(skip, l.scope.WithCallerSkip(skip))
+	return s.(*Scope)
+}
+
+// Info impls grpclog.LoggerV2.
+func (l *grpcLogger) Info(args ...interface{}) {
+	l.log(1, InfoLevel, fmt.Sprint(args...))
+}
+
+// Infoln impls grpclog.LoggerV2.
+func (l *grpcLogger) Infoln(args ...interface{}) {
+	l.log(1, InfoLevel, common.Sprintln(args...))
+}
+
+// Infof impls grpclog.LoggerV2.
+func (l *grpcLogger) Infof(format string, args ...interface{}) {
+	l.log(1, InfoLevel, fmt.Sprintf(format, args...))
+}
+
+// Warning impls grpclog.LoggerV2.
+func (l *grpcLogger) Warning(args ...interface{}) {
+	l.log(1, WarnLevel, fmt.Sprint(args...))
+}
+
+// Warningln impls grpclog.LoggerV2.
+func (l *grpcLogger) Warningln(args ...interface{}) {
+	l.log(1, WarnLevel, common.Sprintln(args...))
+}
+
+// Warningf impls grpclog.LoggerV2.
+func (l *grpcLogger) Warningf(format string, args ...interface{}) {
+	l.log(1, WarnLevel, fmt.Sprintf(format, args...))
+}
+
+// Error impls grpclog.LoggerV2.
+func (l *grpcLogger) Error(args ...interface{}) {
+	l.log(1, ErrorLevel, fmt.Sprint(args...))
+}
+
+// Errorln impls grpclog.LoggerV2.
+func (l *grpcLogger) Errorln(args ...interface{}) {
+	l.log(1, ErrorLevel, common.Sprintln(args...))
+}
+
+// Errorf impls grpclog.LoggerV2.
+func (l *grpcLogger) Errorf(format string, args ...interface{}) {
+	l.log(1, ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+// Fatal impls grpclog.LoggerV2, the process exits even though the logger is disabled.
+func (l *grpcLogger) Fatal(args ...interface{}) {
+	l.log(1, FatalLevel, fmt.Sprint(args...))
+}
+
+// Fatalln impls grpclog.LoggerV2.
+func (l *grpcLogger) Fatalln(args ...interface{}) {
+	l.log(1, FatalLevel, common.Sprintln(args...))
+}
+
+// Fatalf impls grpclog.LoggerV2.
+func (l *grpcLogger) Fatalf(format string, args ...interface{}) {
+	l.log(1, FatalLevel, fmt.Sprintf(format, args...))
+}
+
+// InfoDepth impls grpclog.DepthLoggerV2.
+func (l *grpcLogger) InfoDepth(depth int, args ...interface{}) {
+	l.log(depth+1, InfoLevel, common.Sprintln(args...))
+}
+
+// WarningDepth impls grpclog.DepthLoggerV2.
+func (l *grpcLogger) WarningDepth(depth int, args ...interface{}) {
+	l.log(depth+1, WarnLevel, common.Sprintln(args...))
+}
+
+// ErrorDepth impls grpclog.DepthLoggerV2.
+func (l *grpcLogger) ErrorDepth(depth int, args ...interface{}) {
+	l.log(depth+1, ErrorLevel, common.Sprintln(args...))
+}
+
+// FatalDepth impls grpclog.DepthLoggerV2.
+func (l *grpcLogger) FatalDepth(depth int, args ...interface{}) {
+	l.log(depth+1, FatalLevel, common.Sprintln(args...))
+}
+
+// V impls grpclog.LoggerV2, the verbosity levels are mapped like the glog shim:
+// the level 0 to the info level, and 1 and above to the debug level.
+func (l *grpcLogger) V(level int) bool {
+	lvl := DebugLevel
+	if level <= 0 {
+		lvl = InfoLevel
+	}
+
+	return grpcEnabled.Load() && l.scope.Enabled(lvl)
+}
@@ -0,0 +1,80 @@
+package lager
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/grpclog"
+)
+
+func TestGrpcLogger(t *testing.T) {
+	o := DefaultOptions()
+	o.SetLogCallers(GrpcScopeName, true)
+	buf := configureBuffer(t, o)
+	
+	_, file, line, _ := runtime.Caller(0)
+	grpclog.Infof("hello %d", 1)
+	grpclog.Component("transport").Warning("closing")
+	grpclog.Errorln("failed", 2)
+	assert.True(t, grpclog.V(0))
+	assert.False(t, grpclog.V(2))
+	
+	grpcScope.SetOutputLevel(DebugLevel)
+	assert.True(t, grpclog.V(2))
+	
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 3)
+	for i, e := range entries {
+		assert.Equal(t, GrpcScopeName, e["scope"])
+		assert.True(t, strings.HasSuffix(e["caller"].(string), filepath.Base(file)+":"+strconv.Itoa(line+1+i)),
+			"unexpected caller %v", e["caller"])
+	}
+	
+	assert.Equal(t, "info", entries[0]["level"])
+	assert.Equal(t, "hello 1", entries[0]["msg"])
+	assert.Equal(t, "warn", entries[1]["level"])
+	assert.Equal(t, "[transport] closing", entries[1]["msg"])
+	assert.Equal(t, "error", entries[2]["level"])
+	assert.Equal(t, "failed 2", entries[2]["msg"])
+}
+
+func TestGrpcLoggerDisabled(t *testing.T) {
+	// the logger is set by the first Configure with the LogGrpc.
+	require.NoError(t, Configure(DefaultOptions()))
+	
+	o := DefaultOptions()
+	o.LogGrpc = false
+	buf := configureBuffer(t, o)
+	
+	grpclog.Info("discarded")
+	assert.False(t, grpclog.V(0))
+	assert.Empty(t, buf.String())
+}
+
+func TestGrpcLoggerDisabledFatal(t *testing.T) {
+	if os.Getenv("LAGER_TEST_GRPC_FATAL") == "1" {
+		require.NoError(t, Configure(DefaultOptions()))
+		o := DefaultOptions()
+		o.LogGrpc = false
+		require.NoError(t, Configure(o))
+		
+		grpclog.Fatal("fatal")
+		return
+	}
+	
+	cmd := exec.Command(os.Args[0], "-test.run=^TestGrpcLoggerDisabledFatal$")
+	cmd.Env = append(os.Environ(), "LAGER_TEST_GRPC_FATAL=1")
+	out, err := cmd.CombinedOutput()
+	
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr, "expected to exit even though the logger is disabled: %s", out)
+	assert.Equal(t, 1, exitErr.ExitCode())
+	assert.Contains(t, string(out), "fatal")
+}
@@ -0,0 +1,283 @@
+package lager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	journaldSinkScheme = "journald"
+
+	defaultJournaldSocket     = "/run/systemd/journal/socket"
+	defaultJournaldScopeField = journaldSyslogIdentifier
+	defaultJournaldFallback   = "stderr"
+
+	journaldSyslogIdentifier = "SYSLOG_IDENTIFIER"
+	journaldFieldMaxLen      = 64
+
+	// journaldReservedPrefix prefixes the fields whose names are reserved for the entry, e.g. "message" is FIELD_MESSAGE.
+	journaldReservedPrefix = "FIELD_"
+)
+
+var (
+	// journaldReservedFields the fields of the entry, which aren't taken by the fields of the user,
+	// besides the CODE_* fields of the caller.
+	journaldReservedFields = map[string]struct{}{
+		"MESSAGE":                {},
+		"PRIORITY":               {},
+		journaldSyslogIdentifier: {},
+		"STACK_TRACE":            {},
+	}
+)
+
+type (
+	// journaldSink writes the entries to the journald by its native protocol, see newJournaldSink.
+	journaldSink struct {
+		transport  *netSink
+		identifier string
+		scopeField string
+
+		// the sink and the encoder writing the entries once the journald socket is absent.
+		fallback    Sink
+		fallbackEnc zapcore.Encoder
+	}
+)
+
+func init() {
+	if err := RegisterSink(journaldSinkScheme, newJournaldSink); err != nil {
+		panic(err)
+	}
+}
+
+// newJournaldSink opens the sink of the journald:// URL, e.g.
+//   - journald://, writing to the /run/systemd/journal/socket
+//   - journald:///run/systemd/journal/socket?identifier=my-app&scopeField=LAGER_SCOPE&fallback=stdout
+//
+// the lager levels are mapped to the PRIORITY like the syslog severities, the scope is the SYSLOG_IDENTIFIER
+// or the custom scopeField, the fields are the journal fields, and the caller is the CODE_FILE, CODE_LINE and CODE_FUNC.
+// the SYSLOG_IDENTIFIER defaults to the identifier option, the app id, or the executable name.
+// the fields named like the ones of the entry, e.g. "message" or "code_line", are prefixed by FIELD_.
+// the entries too large for a datagram are passed to the journald by a sealed memfd, on Linux.
+//
+// when the socket is absent, e.g. the process isn't run by systemd, the entries are written
+// in the console format to the fallback path, which is the stderr by default.
+func newJournaldSink(u *url.URL) (Sink, error) {
+	q := u.Query()
+
+	socket := u.Path
+	if socket == "" {
+		socket = defaultJournaldSocket
+	}
+
+	scopeField := defaultJournaldScopeField
+	if v := q.Get("scopeField"); v != "" {
+		if scopeField = journaldFieldName(v); scopeField == "" {
+			return nil, errors.Errorf("invalid journald scope field: %q", v)
+		}
+	}
+
+	fallbackPath := q.Get("fallback")
+	if fallbackPath == "" {
+		fallbackPath = defaultJournaldFallback
+	}
+
+	fallback, err := newSink(fallbackPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the journald fallback")
+	}
+
+	if _, ok := fallback.(EntrySink); ok {
+		_ = fallback.Close()
+		return nil, errors.Errorf("the journald fallback must not be an entry sink: %q", fallbackPath)
+	}
+
+	encCfg := defaultEncoderConfig()
+	encCfg.ConsoleSeparator = defaultConsoleSeparator
+
+	return &journaldSink{
+		transport:   &netSink{network: unixgramSinkScheme, address: socket, timeout: defaultSinkDialTimeout},
+		identifier:  q.Get("identifier"),
+		scopeField:  scopeField,
+		fallback:    fallback,
+		fallbackEnc: zapcore.NewConsoleEncoder(encCfg),
+	}, nil
+}
+
+// WriteEntry impls EntrySink.
+func (s *journaldSink) WriteEntry(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !s.available() {
+		buf, err := s.fallbackEnc.EncodeEntry(ent, fields)
+		if err != nil {
+			return err
+		}
+		defer buf.Free()
+
+		_, err = s.fallback.Write(buf.Bytes())
+		return err
+	}
+
+	return s.send(s.format(ent, fieldsToMap(fields)))
+}
+
+// Write impls zapcore.WriteSyncer, the encoded log data is sent as an informational message.
+func (s *journaldSink) Write(p []byte) (int, error) {
+	if !s.available() {
+		return s.fallback.Write(p)
+	}
+
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now(), Message: string(bytes.TrimRight(p, "\n"))}
+	if err := s.send(s.format(ent, nil)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// send writes the serialized entry as a datagram, or by a file descriptor if too large for a datagram.
+func (s *journaldSink) send(data []byte) error {
+	_, err := s.transport.Write(data)
+	if err != nil && journaldTooLarge(err) {
+		return errors.Wrap(sendJournaldFD(s.transport.address, data), "failed to write the large entry to the journald")
+	}
+
+	return err
+}
+
+// Sync impls zapcore.WriteSyncer.
+func (s *journaldSink) Sync() error {
+	return errors.CombineErrors(s.transport.Sync(), s.fallback.Sync())
+}
+
+// Close impls io.Closer.
+func (s *journaldSink) Close() error {
+	return errors.CombineErrors(s.transport.Close(), s.fallback.Close())
+}
+
+// available returns whether the journald socket exists,
+// it's checked on each write, so that a restarted journald is picked up again.
+func (s *journaldSink) available() bool {
+	_, err := os.Stat(s.transport.address)
+	return err == nil
+}
+
+// format serializes the entry by the journald native protocol:
+// a field per line as KEY=value, or as KEY, a newline, the little-endian 64-bit length and the value
+// when the value contains a newline.
+func (s *journaldSink) format(ent zapcore.Entry, fields map[string]interface{}) []byte {
+	var buf bytes.Buffer
+	write := func(key, value string) {
+		if !strings.Contains(value, "\n") {
+			buf.WriteString(key + "=" + value + "\n")
+			return
+		}
+
+		buf.WriteString(key + "\n")
+		_ = binary.Write(&buf, binary.LittleEndian, uint64(len(value)))
+		buf.WriteString(value + "\n")
+	}
+
+	severity, ok := levelToSyslogSeverity[ent.Level]
+	if !ok {
+		severity = syslogInfo
+	}
+
+	write("MESSAGE", ent.Message)
+	write("PRIORITY", strconv.Itoa(severity))
+
+	identifier := s.identifierOf(fields)
+	if ent.LoggerName != "" {
+		if s.scopeField == journaldSyslogIdentifier {
+			identifier = ent.LoggerName
+		} else {
+			write(s.scopeField, ent.LoggerName)
+		}
+	}
+	write(journaldSyslogIdentifier, identifier)
+
+	if ent.Caller.Defined {
+		write("CODE_FILE", ent.Caller.File)
+		write("CODE_LINE", strconv.Itoa(ent.Caller.Line))
+		if ent.Caller.Function != "" {
+			write("CODE_FUNC", ent.Caller.Function)
+		}
+	}
+
+	if ent.Stack != "" {
+		write("STACK_TRACE", ent.Stack)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if name := s.fieldName(k); name != "" {
+			write(name, fieldValueString(fields[k]))
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// identifierOf returns the SYSLOG_IDENTIFIER, which is the identifier option, the app id field, or the executable name.
+func (s *journaldSink) identifierOf(fields map[string]interface{}) string {
+	if s.identifier != "" {
+		return s.identifier
+	}
+
+	if appID, ok := fields[logPlaceholderAppID].(string); ok && appID != "" {
+		return appID
+	}
+
+	return filepath.Base(os.Args[0])
+}
+
+// fieldName returns the journal field name of the key of a user field,
+// which is prefixed if it's reserved for the entry, the caller or the scope.
+func (s *journaldSink) fieldName(key string) string {
+	name := journaldFieldName(key)
+	if name == "" {
+		return ""
+	}
+
+	if _, ok := journaldReservedFields[name]; ok || name == s.scopeField || strings.HasPrefix(name, "CODE_") {
+		name = journaldFieldName(journaldReservedPrefix + name)
+	}
+
+	return name
+}
+
+// journaldFieldName returns the journal field name of the key, which is made of at most 64 uppercase letters,
+// digits and underscores, and doesn't start with an underscore or a digit, e.g. "@app_id" is APP_ID.
+// an empty name is returned when nothing remains.
+func journaldFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+
+	name = strings.TrimLeft(name, "_0123456789")
+	if len(name) > journaldFieldMaxLen {
+		name = name[:journaldFieldMaxLen]
+	}
+
+	return name
+}
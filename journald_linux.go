@@ -0,0 +1,41 @@
+package lager
+
+import (
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/sys/unix"
+)
+
+// journaldTooLarge reports whether the datagram is rejected for its size.
+func journaldTooLarge(err error) bool {
+	return errors.Is(err, unix.EMSGSIZE) || errors.Is(err, unix.ENOBUFS)
+}
+
+// sendJournaldFD writes the data to a sealed memfd, and passes its file descriptor to the journald socket.
+func sendJournaldFD(socket string, data []byte) error {
+	fd, err := unix.MemfdCreate("journald", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the memfd")
+	}
+
+	f := os.NewFile(uintptr(fd), "journald")
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return errors.Wrap(err, "failed to write the memfd")
+	}
+
+	if _, err := unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS,
+		unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL); err != nil {
+		return errors.Wrap(err, "failed to seal the memfd")
+	}
+
+	sock, err := unix.Socket(unix.AF_UNIX, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the journald socket")
+	}
+	defer unix.Close(sock)
+
+	return unix.Sendmsg(sock, nil, unix.UnixRights(int(f.Fd())), &unix.SockaddrUnix{Name: socket}, 0)
+}
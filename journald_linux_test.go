@@ -0,0 +1,50 @@
+package lager
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+func TestJournaldSinkLargeEntry(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram(unixgramSinkScheme, &net.UnixAddr{Name: socket, Net: unixgramSinkScheme})
+	require.NoError(t, err)
+	defer conn.Close()
+	
+	_, entrySinks, closeFunc, err := openSinks("journald://" + socket)
+	require.NoError(t, err)
+	defer closeFunc()
+	require.Len(t, entrySinks, 1)
+	
+	msg := strings.Repeat("x", 8<<20)
+	zap.New(newEntrySinkCore(zap.InfoLevel, entrySinks[0])).Info(msg)
+	
+	oob := make([]byte, unix.CmsgSpace(4))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, oobn, _, _, err := conn.ReadMsgUnix(make([]byte, 16), oob)
+	require.NoError(t, err)
+	assert.Zero(t, n, "expected the entry passed by the file descriptor")
+	
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	require.NoError(t, err)
+	require.Len(t, fds, 1)
+	
+	f := os.NewFile(uintptr(fds[0]), "memfd")
+	defer f.Close()
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, int64(len(msg))+1024))
+	require.NoError(t, err)
+	assert.True(t, parseJournaldFields(t, data)["MESSAGE"] == msg, "expected the message within the memfd")
+}
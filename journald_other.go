@@ -0,0 +1,17 @@
+//go:build !linux
+
+package lager
+
+import (
+	"github.com/cockroachdb/errors"
+)
+
+// journaldTooLarge reports whether the datagram is rejected for its size, the memfd is only available on Linux.
+func journaldTooLarge(error) bool {
+	return false
+}
+
+// sendJournaldFD isn't supported but on Linux.
+func sendJournaldFD(string, []byte) error {
+	return errors.New("the journald file descriptor passing is only supported on Linux")
+}
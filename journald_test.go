@@ -0,0 +1,134 @@
+package lager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// parseJournaldFields parses a datagram of the journald native protocol.
+func parseJournaldFields(t *testing.T, data []byte) map[string]string {
+	fields := map[string]string{}
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		require.GreaterOrEqual(t, i, 0)
+		line := string(data[:i])
+		data = data[i+1:]
+		
+		if k, v, ok := strings.Cut(line, "="); ok {
+			fields[k] = v
+			continue
+		}
+		
+		size := binary.LittleEndian.Uint64(data[:8])
+		fields[line] = string(data[8 : 8+size])
+		data = data[8+size+1:]
+	}
+	
+	return fields
+}
+
+func TestJournaldSink(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenPacket("unixgram", socket)
+	require.NoError(t, err)
+	defer conn.Close()
+	
+	o := DefaultOptions().WithAppID("my-app")
+	o.OutputPaths = []string{"journald://" + socket}
+	o.SetLogCallers(DefaultScopeName, true)
+	require.NoError(t, Configure(o))
+	defer func() { require.NoError(t, Configure(DefaultOptions())) }()
+	
+	zap.L().Named("ads").Error("multi\nline", zap.String("user-name", "foo"), zap.Int("count", 3),
+		zap.String("message", "bar"), zap.String("code_line", "baz"))
+	
+	buf := make([]byte, 4096)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	
+	fields := parseJournaldFields(t, buf[:n])
+	assert.Equal(t, "multi\nline", fields["MESSAGE"])
+	assert.Equal(t, "3", fields["PRIORITY"])
+	assert.Equal(t, "ads", fields["SYSLOG_IDENTIFIER"])
+	assert.Equal(t, "foo", fields["USER_NAME"])
+	assert.Equal(t, "3", fields["COUNT"])
+	assert.Equal(t, "my-app", fields["APP_ID"])
+	assert.Contains(t, fields["CODE_FILE"], "journald_test.go")
+	assert.NotEmpty(t, fields["CODE_LINE"])
+	assert.Equal(t, "bar", fields["FIELD_MESSAGE"], "expected the reserved names prefixed")
+	assert.Equal(t, "baz", fields["FIELD_CODE_LINE"])
+}
+
+func TestJournaldSinkScopeField(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenPacket("unixgram", socket)
+	require.NoError(t, err)
+	defer conn.Close()
+	
+	_, entrySinks, closeFunc, err := openSinks("journald://" + socket + "?scopeField=lager_scope&identifier=my-app")
+	require.NoError(t, err)
+	defer closeFunc()
+	require.Len(t, entrySinks, 1)
+	
+	zap.New(newEntrySinkCore(zap.DebugLevel, entrySinks[0])).Named("ads").Debug("hello")
+	
+	buf := make([]byte, 4096)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	
+	fields := parseJournaldFields(t, buf[:n])
+	assert.Equal(t, "7", fields["PRIORITY"])
+	assert.Equal(t, "my-app", fields["SYSLOG_IDENTIFIER"])
+	assert.Equal(t, "ads", fields["LAGER_SCOPE"])
+}
+
+func TestJournaldSinkFallback(t *testing.T) {
+	dir := t.TempDir()
+	fallback := filepath.Join(dir, "fallback.log")
+	
+	_, entrySinks, closeFunc, err := openSinks("journald://" + filepath.Join(dir, "absent.sock") + "?fallback=" + fallback)
+	require.NoError(t, err)
+	require.Len(t, entrySinks, 1)
+	
+	zap.New(newEntrySinkCore(zap.InfoLevel, entrySinks[0])).Info("hello", zap.String("key", "value"))
+	_, err = entrySinks[0].Write([]byte("raw\n"))
+	require.NoError(t, err)
+	require.NoError(t, closeFunc())
+	
+	data, err := os.ReadFile(fallback)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "hello")
+	assert.Contains(t, string(data), `{"key": "value"}`)
+	assert.Contains(t, string(data), "raw\n")
+}
+
+func TestJournaldSinkErrors(t *testing.T) {
+	for _, path := range []string{
+		"journald://?scopeField=___",
+		"journald://?fallback=unknown://foo",
+		"journald://?fallback=journald://",
+	} {
+		_, _, _, err := openSinks(path)
+		assert.Error(t, err, "expected to fail to open %q", path)
+	}
+}
+
+func TestJournaldFieldName(t *testing.T) {
+	assert.Equal(t, "APP_ID", journaldFieldName("@app_id"))
+	assert.Equal(t, "USER_NAME", journaldFieldName("user.name"))
+	assert.Equal(t, "A1", journaldFieldName("_1a1"))
+	assert.Equal(t, "", journaldFieldName("__"))
+	assert.Len(t, journaldFieldName(strings.Repeat("a", 100)), journaldFieldMaxLen)
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/cockroachdb/errors"
+	"go.uber.org/zap/zapcore"
 )
 
 type (
@@ -44,6 +45,17 @@ var (
 		"none":  NoneLevel,
 	}
 
+	// levelToZap maps the log levels to the zap levels,
+	// the NoneLevel is mapped to a level above the zap fatal level, so that nothing is enabled.
+	levelToZap = map[Level]zapcore.Level{
+		DebugLevel: zapcore.DebugLevel,
+		InfoLevel:  zapcore.InfoLevel,
+		WarnLevel:  zapcore.WarnLevel,
+		ErrorLevel: zapcore.ErrorLevel,
+		FatalLevel: zapcore.FatalLevel,
+		NoneLevel:  zapcore.FatalLevel + 1,
+	}
+
 	errUnmarshalNilLevel = errors.New("can't unmarshal a nil *Level")
 )
 
@@ -112,7 +124,8 @@ func (l *Level) Get() interface{} {
 	return *l
 }
 
-// Enabled returns true if the given level is at or above this level.
+// Enabled returns true if the given level is at or above this level in severity,
+// e.g. the InfoLevel enables the WarnLevel but not the DebugLevel, and the NoneLevel enables nothing.
 func (l Level) Enabled(lvl Level) bool {
-	return lvl >= l
+	return lvl != NoneLevel && lvl <= l
 }
@@ -5,6 +5,11 @@ import (
 )
 
 type (
+	// LevelEnabler decides whether a given logging level is enabled when logging a message.
+	LevelEnabler interface {
+		Enabled(Level) bool
+	}
+	
 	// LevelEnablerFunc is a convenient way to implement LevelEnabler with
 	// an anonymous function.
 	//
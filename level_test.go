@@ -149,3 +149,14 @@ func TestLevelAsFlagValue(t *testing.T) {
 		"unexpected error output from invalid flag input.",
 	)
 }
+
+func TestLevelEnabled(t *testing.T) {
+	assert.True(t, InfoLevel.Enabled(InfoLevel))
+	assert.True(t, InfoLevel.Enabled(ErrorLevel))
+	assert.True(t, InfoLevel.Enabled(FatalLevel))
+	assert.False(t, InfoLevel.Enabled(DebugLevel))
+	assert.False(t, InfoLevel.Enabled(NoneLevel))
+	assert.True(t, DebugLevel.Enabled(DebugLevel))
+	assert.False(t, NoneLevel.Enabled(FatalLevel))
+	assert.False(t, NoneLevel.Enabled(NoneLevel))
+}
@@ -0,0 +1,357 @@
+package lager
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	uatomic "go.uber.org/atomic"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// the value disabling the sampling or the rate limit of a scope, e.g. "ads:off".
+	limitOff = "off"
+	// the separator of the values of a sampling or a rate limit, e.g. "ads:100/10".
+	limitValueSeparator = "/"
+
+	defaultSamplingTick       = time.Second
+	defaultDropReportInterval = 10 * time.Second
+
+	// droppedKey the key of the field carrying the number of the dropped entries of a summary.
+	droppedKey = "dropped"
+)
+
+type (
+	// limitConfig the sampling and the rate limit of a scope, the zero value limits nothing.
+	limitConfig struct {
+		// log the first entries of a message per tick, and every thereafter entry after that.
+		first, thereafter int
+		tick              time.Duration
+
+		// the token bucket of each level, which is refilled by the rate per second up to the burst.
+		rate  float64
+		burst int
+	}
+
+	// scopeLimits the state of the sampling and the rate limits of a scope, shared by the copies of the scope.
+	scopeLimits struct {
+		cfg     limitConfig
+		dropped *uatomic.Int64
+		// the token buckets of the debug, info, warn and error levels.
+		buckets [zapcore.ErrorLevel - zapcore.DebugLevel + 1]*tokenBucket
+
+		mu sync.Mutex
+		// the base of the latest Configure and its limited core.
+		base *zapBase
+		core zapcore.Core
+	}
+
+	// tokenBucket a token bucket rate limiter.
+	tokenBucket struct {
+		mu     sync.Mutex
+		rate   float64
+		burst  float64
+		tokens float64
+		last   time.Time
+	}
+
+	// limitCore writes the entries to the limited core, except for the entries above the error level,
+	// which are never dropped, since they panic or exit, and the entries of the unsampled routes.
+	limitCore struct {
+		zapcore.Core
+		limited zapcore.Core
+	}
+
+	// rateLimitCore drops the entries beyond the token buckets of their levels.
+	rateLimitCore struct {
+		zapcore.Core
+		limits *scopeLimits
+	}
+)
+
+var (
+	// dropReportsMu guards the stopDropReports.
+	dropReportsMu sync.Mutex
+	// stopDropReports stops the reports of the latest Configure.
+	stopDropReports func()
+)
+
+// WithSampling sets the sampling of the scopes by the "scope:first/thereafter" pairs separated by the ',',
+// like the output levels, e.g. "@default:100/10,ads:10/100,xds:off",
+// where the first entries of a message per the SamplingTick are logged, and every thereafter entry after that,
+// the thereafter of 0 drops all entries after the first ones, and the "off" disables the sampling.
+// a scope without its own sampling takes the one of the default scope.
+func (o *Options) WithSampling(samplings string) *Options {
+	o.samplings = samplings
+	return o
+}
+
+// WithRateLimit sets the rate limits of the scopes by the "scope:rate/burst" pairs separated by the ',',
+// like the output levels, e.g. "@default:1000,ads:10/50,xds:off",
+// where each level of a scope has its own token bucket, which is refilled by the rate per second
+// up to the burst, the burst defaults to the rate, and the "off" disables the rate limit.
+// a scope without its own rate limit takes the one of the default scope.
+func (o *Options) WithRateLimit(rateLimits string) *Options {
+	o.rateLimits = rateLimits
+	return o
+}
+
+// SetSampling sets the sampling of the scope, see the WithSampling, the first of 0 disables it.
+func (o *Options) SetSampling(scope string, first, thereafter int) {
+	value := limitOff
+	if first > 0 {
+		value = strconv.Itoa(first) + limitValueSeparator + strconv.Itoa(thereafter)
+	}
+
+	o.samplings = setLevel(o.samplings, scope, value)
+}
+
+// GetSampling returns the sampling of the scope, the first of 0 if none.
+func (o *Options) GetSampling(scope string) (first, thereafter int, err error) {
+	value, ok := getScopeValue(o.samplings, scope)
+	if !ok || value == limitOff {
+		return 0, 0, nil
+	}
+
+	f, t, _ := strings.Cut(value, limitValueSeparator)
+	if first, err = strconv.Atoi(f); err != nil || first <= 0 {
+		return 0, 0, errors.Errorf("invalid sampling for scope %q: %q", scope, value)
+	}
+	if thereafter, err = strconv.Atoi(t); err != nil || thereafter < 0 {
+		return 0, 0, errors.Errorf("invalid sampling for scope %q: %q", scope, value)
+	}
+
+	return first, thereafter, nil
+}
+
+// SetRateLimit sets the rate limit of the scope, see the WithRateLimit, the rate of 0 disables it.
+func (o *Options) SetRateLimit(scope string, rate float64, burst int) {
+	value := limitOff
+	if rate > 0 {
+		value = strconv.FormatFloat(rate, 'f', -1, 64) + limitValueSeparator + strconv.Itoa(burst)
+	}
+
+	o.rateLimits = setLevel(o.rateLimits, scope, value)
+}
+
+// GetRateLimit returns the rate limit of the scope, the rate of 0 if none.
+func (o *Options) GetRateLimit(scope string) (rate float64, burst int, err error) {
+	value, ok := getScopeValue(o.rateLimits, scope)
+	if !ok || value == limitOff {
+		return 0, 0, nil
+	}
+
+	r, b, hasBurst := strings.Cut(value, limitValueSeparator)
+	if rate, err = strconv.ParseFloat(r, 64); err != nil || rate <= 0 || math.IsInf(rate, 0) {
+		return 0, 0, errors.Errorf("invalid rate limit for scope %q: %q", scope, value)
+	}
+
+	burst = int(math.Ceil(rate))
+	if hasBurst {
+		if burst, err = strconv.Atoi(b); err != nil || burst <= 0 {
+			return 0, 0, errors.Errorf("invalid rate limit for scope %q: %q", scope, value)
+		}
+	}
+
+	return rate, burst, nil
+}
+
+// limitConfig returns the sampling and the rate limit of the scope by the options,
+// each of which is that of the default scope if unspecified.
+func (o *Options) limitConfig(scope string) (cfg limitConfig, err error) {
+	samplingScope, rateLimitScope := scope, scope
+	if _, ok := getScopeValue(o.samplings, scope); !ok {
+		samplingScope = DefaultScopeName
+	}
+	if _, ok := getScopeValue(o.rateLimits, scope); !ok {
+		rateLimitScope = DefaultScopeName
+	}
+
+	if cfg.first, cfg.thereafter, err = o.GetSampling(samplingScope); err != nil {
+		return cfg, err
+	}
+	if cfg.rate, cfg.burst, err = o.GetRateLimit(rateLimitScope); err != nil {
+		return cfg, err
+	}
+
+	cfg.tick = o.SamplingTick
+	if cfg.tick <= 0 {
+		cfg.tick = defaultSamplingTick
+	}
+
+	return cfg, nil
+}
+
+// newScopeLimits returns the limits of the config, or nil if it limits nothing,
+// the dropped entries are counted by the given counter, e.g. the one of the previous limits, if not nil.
+func newScopeLimits(cfg limitConfig, dropped *uatomic.Int64) *scopeLimits {
+	if cfg.first <= 0 && cfg.rate <= 0 {
+		return nil
+	}
+
+	if dropped == nil {
+		dropped = uatomic.NewInt64(0)
+	}
+
+	l := &scopeLimits{cfg: cfg, dropped: dropped}
+	if cfg.rate > 0 {
+		for i := range l.buckets {
+			l.buckets[i] = newTokenBucket(cfg.rate, cfg.burst)
+		}
+	}
+
+	return l
+}
+
+// wrap returns the core of the base limited by the scope limits, which is built once per base,
+// so that the copies of the scope and the global logger share the counters.
+func (l *scopeLimits) wrap(b *zapBase) zapcore.Core {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.base == b {
+		return l.core
+	}
+
+	var limited = b.core
+	if l.cfg.rate > 0 {
+		limited = &rateLimitCore{Core: limited, limits: l}
+	}
+	if l.cfg.first > 0 {
+		limited = zapcore.NewSamplerWithOptions(limited, l.cfg.tick, l.cfg.first, l.cfg.thereafter,
+			zapcore.SamplerHook(func(_ zapcore.Entry, dec zapcore.SamplingDecision) {
+				if dec&zapcore.LogDropped != 0 {
+					l.dropped.Inc()
+				}
+			}))
+	}
+
+	l.base, l.core = b, &limitCore{Core: b.core, limited: limited}
+	return l.core
+}
+
+// allow reports whether the entry of the level is allowed by the token bucket of the level.
+func (l *scopeLimits) allow(level zapcore.Level) bool {
+	if level < zapcore.DebugLevel || level > zapcore.ErrorLevel {
+		return true
+	}
+
+	if b := l.buckets[level-zapcore.DebugLevel]; b != nil && !b.allow(time.Now()) {
+		l.dropped.Inc()
+		return false
+	}
+
+	return true
+}
+
+// newTokenBucket returns a full token bucket.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// allow takes a token if any.
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// With impls zapcore.Core.
+func (c *limitCore) With(fields []zapcore.Field) zapcore.Core {
+	return &limitCore{Core: c.Core.With(fields), limited: c.limited.With(fields)}
+}
+
+// Check impls zapcore.Core.
+func (c *limitCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if e.Level > zapcore.ErrorLevel || unsampled(e) {
+		return c.Core.Check(e, ce)
+	}
+
+	return c.limited.Check(e, ce)
+}
+
+// With impls zapcore.Core.
+func (c *rateLimitCore) With(fields []zapcore.Field) zapcore.Core {
+	return &rateLimitCore{Core: c.Core.With(fields), limits: c.limits}
+}
+
+// Check impls zapcore.Core.
+func (c *rateLimitCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(e.Level) || !c.limits.allow(e.Level) {
+		return ce
+	}
+
+	return c.Core.Check(e, ce)
+}
+
+// reportDrops writes the "dropped N messages" summary of each scope, which has dropped entries since the last report,
+// at the warn level, bypassing the limits of the scope.
+func reportDrops() {
+	b := base.Load()
+	for _, s := range sortedScopes() {
+		l := s.limits.Load()
+		if l == nil {
+			continue
+		}
+
+		n := l.dropped.Swap(0)
+		if n <= 0 {
+			continue
+		}
+
+		logger := zap.New(b.core, zap.ErrorOutput(b.errSink))
+		if s.name != DefaultScopeName {
+			logger = logger.Named(s.name)
+		}
+		logger.Warn("dropped "+strconv.FormatInt(n, 10)+" messages", zap.Int64(droppedKey, n))
+	}
+}
+
+// startDropReports reports the drops every interval until the next Configure, the non-positive interval stops them.
+func startDropReports(interval time.Duration) {
+	var stop func()
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		done := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case <-ticker.C:
+					reportDrops()
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		stop = func() {
+			ticker.Stop()
+			close(done)
+		}
+	}
+
+	dropReportsMu.Lock()
+	prev := stopDropReports
+	stopDropReports = stop
+	dropReportsMu.Unlock()
+
+	if prev != nil {
+		prev()
+	}
+}
@@ -0,0 +1,176 @@
+package lager
+
+import (
+	"testing"
+	"time"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestOptionsLimits(t *testing.T) {
+	o := DefaultOptions()
+	
+	first, thereafter, err := o.GetSampling("ads")
+	assert.NoError(t, err)
+	assert.Zero(t, first)
+	assert.Zero(t, thereafter)
+	
+	o.SetSampling("ads", 100, 10)
+	o.SetSampling("xds", 0, 10)
+	assert.Equal(t, "ads:100/10,xds:off", o.samplings)
+	first, thereafter, err = o.GetSampling("ads")
+	assert.NoError(t, err)
+	assert.Equal(t, 100, first)
+	assert.Equal(t, 10, thereafter)
+	
+	o.SetRateLimit("ads", 0.5, 2)
+	rate, burst, err := o.GetRateLimit("ads")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, rate)
+	assert.Equal(t, 2, burst)
+	
+	o.WithRateLimit("10,ads:off")
+	rate, burst, err = o.GetRateLimit(DefaultScopeName)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(10), rate)
+	assert.Equal(t, 10, burst, "expected the burst defaulting to the rate")
+	
+	// a scope without its own limits takes those of the default scope, unless off.
+	o.WithSampling("@default:5/0,ads:off")
+	cfg, err := o.limitConfig("unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, limitConfig{first: 5, rate: 10, burst: 10, tick: defaultSamplingTick}, cfg)
+	cfg, err = o.limitConfig("ads")
+	assert.NoError(t, err)
+	assert.Equal(t, limitConfig{tick: defaultSamplingTick}, cfg)
+	
+	o.WithSampling("@all:1/1")
+	first, _, err = o.GetSampling("ads")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, first)
+	
+	for _, samplings := range []string{"ads:0/1", "ads:1", "ads:x/1", "ads:1/-1"} {
+		_, _, err = o.WithSampling(samplings).GetSampling("ads")
+		assert.Error(t, err, "expected to fail to parse %q", samplings)
+	}
+	
+	for _, rateLimits := range []string{"ads:0", "ads:x", "ads:1/0", "ads:1/x"} {
+		_, _, err = o.WithRateLimit(rateLimits).GetRateLimit("ads")
+		assert.Error(t, err, "expected to fail to parse %q", rateLimits)
+	}
+	
+	assert.Error(t, Configure(DefaultOptions().WithSampling("foo")))
+}
+
+func TestSampling(t *testing.T) {
+	s := RegisterScope("test-sampling", "")
+	other := RegisterScope("test-sampling-other", "")
+	o := DefaultOptions().WithSampling("test-sampling:2/3")
+	o.SamplingTick = time.Hour
+	o.DropReportInterval = time.Hour
+	buf := configureBuffer(t, o)
+	
+	for i := 0; i < 10; i++ {
+		s.Info("chatty", zap.Int("i", i))
+		other.Info("chatty", zap.Int("i", i))
+	}
+	s.Info("another")
+	
+	entries := decodeLines(t, buf)
+	var logged []interface{}
+	for _, e := range entries {
+		if e["scope"] == "test-sampling" {
+			logged = append(logged, e["i"])
+		}
+	}
+	assert.Equal(t, []interface{}{float64(0), float64(1), float64(4), float64(7), nil}, logged)
+	assert.Len(t, entries, 15, "expected the other scope not sampled")
+	
+	buf.Reset()
+	reportDrops()
+	reportDrops()
+	entries = decodeLines(t, buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "warn", entries[0]["level"])
+	assert.Equal(t, "test-sampling", entries[0]["scope"])
+	assert.Equal(t, "dropped 6 messages", entries[0]["msg"])
+	assert.Equal(t, float64(6), entries[0][droppedKey])
+}
+
+func TestRateLimit(t *testing.T) {
+	s := RegisterScope("test-rate-limit", "")
+	o := DefaultOptions().WithRateLimit("test-rate-limit:0.001/2")
+	o.DropReportInterval = time.Hour
+	buf := configureBuffer(t, o)
+	
+	for i := 0; i < 3; i++ {
+		s.Info("info")
+		s.WithCallerSkip(1).Info("info")
+		s.Error("error")
+	}
+	
+	entries := decodeLines(t, buf)
+	var infos, errs int
+	for _, e := range entries {
+		switch e["level"] {
+		case "info":
+			infos++
+		case "error":
+			errs++
+		}
+	}
+	assert.Equal(t, 2, infos, "expected the copies sharing the bucket")
+	assert.Equal(t, 2, errs, "expected a bucket per level")
+	
+	buf.Reset()
+	reportDrops()
+	entries = decodeLines(t, buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, float64(5), entries[0][droppedKey])
+}
+
+func TestGlobalLoggerLimits(t *testing.T) {
+	o := DefaultOptions().WithSampling("1/0")
+	o.SamplingTick = time.Hour
+	o.DropReportInterval = time.Hour
+	buf := configureBuffer(t, o)
+	
+	for i := 0; i < 3; i++ {
+		zap.L().Info("global")
+		defaultScope.Info("global")
+	}
+	
+	entries := decodeLines(t, buf)
+	assert.Len(t, entries, 1, "expected the global logger sharing the sampling of the default scope")
+	
+	// the scopes registered later take the limits of the default scope.
+	s := RegisterScope("test-global-limits", "")
+	s.Info("scope")
+	s.Info("scope")
+	assert.Len(t, decodeLines(t, buf), 2)
+	
+	buf.Reset()
+	reportDrops()
+	entries = decodeLines(t, buf)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "dropped 5 messages", entries[0]["msg"])
+	assert.Equal(t, "dropped 1 messages", entries[1]["msg"])
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(2, 2)
+	
+	assert.True(t, b.allow(now))
+	assert.True(t, b.allow(now))
+	assert.False(t, b.allow(now))
+	assert.True(t, b.allow(now.Add(500*time.Millisecond)))
+	assert.False(t, b.allow(now.Add(500*time.Millisecond)))
+	
+	// refilled up to the burst.
+	assert.True(t, b.allow(now.Add(time.Hour)))
+	assert.True(t, b.allow(now.Add(time.Hour)))
+	assert.False(t, b.allow(now.Add(time.Hour)))
+}
@@ -0,0 +1,20 @@
+package logrsink
+
+import (
+	"github.com/dapings/lager"
+	"go.uber.org/zap"
+	"k8s.io/klog/v2"
+)
+
+// RedirectKlog routes the klog to the scope by the logr.Logger of the New,
+// and returns a function restoring the klog output, e.g. for tests.
+//
+// the V-levels are still gated by the -v of the klog before they are mapped to the lager levels,
+// so the -v of the klog has to be raised to pass the debug entries.
+func RedirectKlog(scope *lager.Scope) func() {
+	klog.SetLoggerWithOptions(New(scope),
+		klog.ContextualLogger(true),
+		klog.FlushLogger(func() { _ = zap.L().Sync() }))
+
+	return klog.ClearLogger
+}
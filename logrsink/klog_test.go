@@ -0,0 +1,29 @@
+package logrsink
+
+import (
+	"testing"
+
+	"github.com/dapings/lager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2"
+)
+
+func TestRedirectKlog(t *testing.T) {
+	scope := lager.RegisterScope("klog-test", "")
+	entries := configure(t, lager.DefaultOptions())
+
+	restore := RedirectKlog(scope)
+	klog.InfoS("pod updated", "pod", "kube-dns")
+	klog.Errorf("failed %d", 1)
+	klog.Flush()
+	restore()
+
+	got := entries()
+	require.Len(t, got, 2)
+	assert.Equal(t, "pod updated", got[0]["msg"])
+	assert.Equal(t, "kube-dns", got[0]["pod"])
+	assert.Equal(t, "klog-test", got[0]["scope"])
+	assert.Equal(t, "error", got[1]["level"])
+	assert.Equal(t, "failed 1", got[1]["msg"])
+}
@@ -0,0 +1,118 @@
+// Package logrsink bridges the github.com/go-logr/logr and the klog to the lager scopes,
+// like the glog package shims the glog.
+package logrsink
+
+import (
+	"strings"
+
+	"github.com/dapings/lager"
+	"github.com/dapings/lager/common"
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+)
+
+type (
+	// sink the logr.LogSink writing to a lager scope.
+	sink struct {
+		scope  *lager.Scope
+		fields []zap.Field
+		// the caller skip of the logr.Logger and the WithCallDepth.
+		callDepth int
+		// the copy of the scope skipping the callers, the logr.Logger and the method of the sink.
+		logger *lager.Scope
+	}
+)
+
+var (
+	_ logr.LogSink          = (*sink)(nil)
+	_ logr.CallDepthLogSink = (*sink)(nil)
+)
+
+// New returns a logr.Logger writing to the scope, where
+//   - the V-levels are mapped like the glog shim: V(0) to the info level, V(1) and above to the debug level,
+//   - the names are joined to the name of the scope by a dot, which is the registered scope of the joined name if any,
+//     or else a child of the scope sharing its levels, see the lager.Scope.Named,
+//   - the key/values are mapped to the zap fields, and the errors are logged at the error level.
+func New(scope *lager.Scope) logr.Logger {
+	return logr.New(NewLogSink(scope))
+}
+
+// NewLogSink returns a logr.LogSink writing to the scope, see New.
+func NewLogSink(scope *lager.Scope) logr.LogSink {
+	return (&sink{scope: scope}).withSkip()
+}
+
+// withSkip sets the logger skipping the callers of the sink, and returns the sink.
+func (s *sink) withSkip() *sink {
+	s.logger = s.scope.WithCallerSkip(s.callDepth + 2)
+	return s
+}
+
+// levelOf returns the lager level of the V-level.
+func levelOf(level int) lager.Level {
+	if level <= 0 {
+		return lager.InfoLevel
+	}
+
+	return lager.DebugLevel
+}
+
+// Init impls logr.LogSink.
+func (s *sink) Init(info logr.RuntimeInfo) {
+	s.callDepth += info.CallDepth
+	s.withSkip()
+}
+
+// Enabled impls logr.LogSink.
+func (s *sink) Enabled(level int) bool {
+	return s.scope.Enabled(levelOf(level))
+}
+
+// Info impls logr.LogSink.
+func (s *sink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.log(levelOf(level), msg, nil, keysAndValues)
+}
+
+// Error impls logr.LogSink.
+func (s *sink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.log(lager.ErrorLevel, msg, err, keysAndValues)
+}
+
+// WithValues impls logr.LogSink.
+func (s *sink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	clone := *s
+	clone.fields = append(append([]zap.Field(nil), s.fields...), common.KVToFields(nil, keysAndValues)...)
+
+	return &clone
+}
+
+// WithName impls logr.LogSink, the name is joined to the name of the scope,
+// the scopes of the names aren't registered, so that the dynamic names don't pile up in the registry.
+func (s *sink) WithName(name string) logr.LogSink {
+	clone := *s
+	clone.scope = s.scope.Named(name)
+	if registered := lager.FindScope(clone.scope.Name()); registered != nil {
+		clone.scope = registered
+	}
+
+	return clone.withSkip()
+}
+
+// WithCallDepth impls logr.CallDepthLogSink.
+func (s *sink) WithCallDepth(depth int) logr.LogSink {
+	clone := *s
+	clone.callDepth += depth
+
+	return clone.withSkip()
+}
+
+// log writes the entry to the scope, the callers are the logr.Logger and the method of the sink.
+func (s *sink) log(level lager.Level, msg string, err error, keysAndValues []interface{}) {
+	if !s.scope.Enabled(level) {
+		return
+	}
+
+	fields := append(append([]zap.Field(nil), s.fields...), common.KVToFields(err, keysAndValues)...)
+	// the klog formatted messages end with a newline.
+	s.logger.Log(level, strings.TrimSuffix(msg, "\n"), fields...)
+}
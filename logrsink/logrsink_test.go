@@ -0,0 +1,100 @@
+package logrsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/dapings/lager"
+	"github.com/dapings/lager/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configure configures the lager JSON lines written to the returned function decoding them.
+func configure(t *testing.T, o *lager.Options) func() []map[string]interface{} {
+	var buf bytes.Buffer
+	o.OutputPaths = nil
+	o.JSONEncoding = true
+	o.SpecificWriters = []io.Writer{&buf}
+	require.NoError(t, lager.Configure(o))
+	t.Cleanup(func() { _ = lager.Configure(lager.DefaultOptions()) })
+
+	return func() []map[string]interface{} {
+		var entries []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+
+			var entry map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			entries = append(entries, entry)
+		}
+
+		return entries
+	}
+}
+
+func TestLogger(t *testing.T) {
+	scope := lager.RegisterScope("logr-test", "")
+	o := lager.DefaultOptions()
+	o.SetLogCallers("logr-test", true)
+	entries := configure(t, o)
+
+	logger := New(scope).WithValues("tenant", "a")
+	_, file, line, _ := runtime.Caller(0)
+	logger.Info("hello", "count", 1)
+	logger.V(1).Info("filtered")
+	logger.WithName("child").Error(errors.New("boom"), "failed", "odd")
+
+	scope.SetOutputLevel(lager.DebugLevel)
+	assert.True(t, logger.V(3).Enabled())
+	logger.V(3).Info("debug")
+
+	got := entries()
+	require.Len(t, got, 3)
+	assert.Equal(t, "info", got[0]["level"])
+	assert.Equal(t, "logr-test", got[0]["scope"])
+	assert.Equal(t, "a", got[0]["tenant"])
+	assert.Equal(t, float64(1), got[0]["count"])
+	assert.True(t, strings.HasSuffix(got[0]["caller"].(string), filepath.Base(file)+":"+strconv.Itoa(line+1)),
+		"unexpected caller %v", got[0]["caller"])
+
+	assert.Equal(t, "error", got[1]["level"])
+	assert.Equal(t, "logr-test.child", got[1]["scope"])
+	assert.Equal(t, "boom", got[1]["error"])
+	assert.Equal(t, common.MissingValue, got[1]["odd"])
+	assert.Equal(t, "a", got[1]["tenant"])
+	assert.Nil(t, lager.FindScope("logr-test.child"), "expected the names not registered")
+
+	assert.Equal(t, "debug", got[2]["level"])
+}
+
+func TestLoggerWithName(t *testing.T) {
+	scope := lager.RegisterScope("logr-named", "")
+	registered := lager.RegisterScope("logr-named.registered", "")
+	entries := configure(t, lager.DefaultOptions())
+
+	logger := New(scope)
+	dynamic := logger.WithName("pod:a,b")
+	assert.False(t, dynamic.V(1).Enabled())
+	scope.SetOutputLevel(lager.DebugLevel)
+	assert.True(t, dynamic.V(1).Enabled(), "expected the levels of the parent scope")
+	dynamic.V(1).Info("debug")
+
+	registered.SetOutputLevel(lager.ErrorLevel)
+	logger.WithName("registered").Info("filtered")
+	logger.WithName("registered").Error(nil, "error")
+
+	got := entries()
+	require.Len(t, got, 2)
+	assert.Equal(t, "logr-named.pod_a_b", got[0]["scope"])
+	assert.Equal(t, "logr-named.registered", got[1]["scope"])
+}
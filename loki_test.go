@@ -0,0 +1,170 @@
+package lager
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dapings/lager/batch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+// pushReceiver an httptest server recording the pushed requests, the first failures requests fail with the status.
+type pushReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	failures *atomic.Int32
+	status   int
+	response string
+	// the responses of the first requests, before the response.
+	responses []string
+}
+
+func newPushReceiver(t *testing.T) *pushReceiver {
+	r := &pushReceiver{failures: atomic.NewInt32(0), status: http.StatusServiceUnavailable}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if r.failures.Dec() >= 0 {
+			w.WriteHeader(r.status)
+			return
+		}
+
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, string(body))
+		response := r.response
+		if len(r.responses) > 0 {
+			response, r.responses = r.responses[0], r.responses[1:]
+		}
+		r.mu.Unlock()
+
+		_, _ = io.WriteString(w, response)
+	}))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *pushReceiver) received() ([]*http.Request, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.requests, r.bodies
+}
+
+func TestLokiSink(t *testing.T) {
+	receiver := newPushReceiver(t)
+	receiver.failures.Store(1)
+
+	o := DefaultOptions().WithAppID("my-app").WithLokiOutput(LokiConfig{
+		URL:      strings.Replace(receiver.URL, "http://", "http://user:secret@", 1) + "/loki/api/v1/push",
+		TenantID: "team-a",
+		Labels:   map[string]string{"env": "prod"},
+		Batch:    batch.Config{FlushInterval: time.Hour, InitialBackoff: time.Millisecond},
+	})
+	o.OutputPaths = nil
+	require.NoError(t, Configure(o))
+
+	zap.L().Named("ads").Info("hello", zap.String("key", "value"))
+	zap.L().Named("ads").Info("world")
+	zap.L().Warn("warned")
+	require.NoError(t, zap.L().Sync())
+	require.NoError(t, Configure(DefaultOptions()))
+
+	requests, bodies := receiver.received()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/loki/api/v1/push", requests[0].URL.Path)
+	assert.Equal(t, "team-a", requests[0].Header.Get(lokiTenantHeader))
+	user, password, ok := requests[0].BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "user", user)
+	assert.Equal(t, "secret", password)
+
+	var req lokiPushRequest
+	require.NoError(t, json.Unmarshal([]byte(bodies[0]), &req))
+	require.Len(t, req.Streams, 2)
+	assert.Equal(t, map[string]string{"env": "prod", "scope": "ads", "level": "info", "app_id": "my-app"}, req.Streams[0].Stream)
+	assert.Equal(t, map[string]string{"env": "prod", "scope": DefaultScopeName, "level": "warn", "app_id": "my-app"}, req.Streams[1].Stream)
+	require.Len(t, req.Streams[0].Values, 2)
+	assert.Contains(t, req.Streams[0].Values[0][1], `"msg":"hello"`)
+	assert.Contains(t, req.Streams[0].Values[0][1], `"key":"value"`)
+	assert.Contains(t, req.Streams[0].Values[1][1], `"msg":"world"`)
+}
+
+func TestLokiSinkDropped(t *testing.T) {
+	receiver := newPushReceiver(t)
+	errPath := filepath.Join(t.TempDir(), "err.log")
+
+	o := DefaultOptions().WithLokiOutput(LokiConfig{
+		URL:   receiver.URL,
+		Batch: batch.Config{MaxQueueSize: 1, FlushInterval: time.Hour, DropReportInterval: 10 * time.Millisecond},
+	})
+	o.OutputPaths = nil
+	o.ErrOutputPaths = []string{errPath}
+	require.NoError(t, Configure(o))
+	defer func() { require.NoError(t, Configure(DefaultOptions())) }()
+
+	for i := 0; i < 3; i++ {
+		zap.L().Info("hello")
+	}
+
+	require.Eventually(t, func() bool {
+		data, _ := os.ReadFile(errPath)
+		return strings.Contains(string(data), "the loki sink dropped 2 messages")
+	}, time.Second, time.Millisecond)
+	data, err := os.ReadFile(errPath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "write error", "expected the drops not failing the writes")
+}
+
+func TestLokiSinkFromURL(t *testing.T) {
+	receiver := newPushReceiver(t)
+
+	_, entrySinks, closeFunc, err := openSinks("loki://" + strings.TrimPrefix(receiver.URL, "http://") +
+		"?tenant=team-b&label.env=dev&batchSize=10&flushInterval=1h")
+	require.NoError(t, err)
+	require.Len(t, entrySinks, 1)
+
+	_, err = entrySinks[0].Write([]byte("raw\n"))
+	require.NoError(t, err)
+	require.NoError(t, closeFunc(), "expected the pending entries to be pushed on close")
+
+	requests, bodies := receiver.received()
+	require.Len(t, requests, 1)
+	assert.Equal(t, defaultLokiPushPath, requests[0].URL.Path)
+	assert.Equal(t, "team-b", requests[0].Header.Get(lokiTenantHeader))
+	assert.Contains(t, bodies[0], `"env":"dev"`)
+	assert.Contains(t, bodies[0], `raw`)
+}
+
+func TestLokiSinkErrors(t *testing.T) {
+	for _, path := range []string{
+		"loki://",
+		"loki://localhost?tls=foo",
+		"loki://localhost?batchSize=foo",
+		"loki://localhost?flushInterval=foo",
+		"loki://localhost?label.in-valid=foo",
+	} {
+		_, _, _, err := openSinks(path)
+		assert.Error(t, err, "expected to fail to open %q", path)
+	}
+
+	_, err := NewLokiSink(LokiConfig{URL: "ftp://localhost"})
+	assert.Error(t, err)
+
+	o := DefaultOptions().WithLokiOutput(LokiConfig{URL: "://"})
+	assert.Error(t, Configure(o))
+}
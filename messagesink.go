@@ -0,0 +1,167 @@
+package lager
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+type (
+	// MessageSink A message queue producer, e.g. a Kafka or NATS producer,
+	// so that the log can be published without lager depending on the clients.
+	MessageSink interface {
+		// Publish publishes the value keyed by the key, the key is empty when the entry has no key.
+		Publish(ctx context.Context, key, value []byte) error
+	}
+
+	// Message a message published to the MemoryMessageSink.
+	Message struct {
+		Key   []byte
+		Value []byte
+	}
+
+	// MemoryMessageSink an in-memory MessageSink for tests.
+	MemoryMessageSink struct {
+		mu         sync.Mutex
+		messages   []Message
+		publishErr error
+	}
+
+	// messageSinkCore encodes the entries with the encoder and publishes them to a MessageSink.
+	messageSinkCore struct {
+		zapcore.LevelEnabler
+		enc      zapcore.Encoder
+		sink     MessageSink
+		keyField string
+		// the key of the fields added by With, if any.
+		key string
+	}
+
+	// messageSinkOption a message sink registered by the Options WithMessageSink.
+	messageSinkOption struct {
+		sink     MessageSink
+		keyField string
+	}
+)
+
+// NewMessageSinkCore returns a zapcore.Core that encodes the entries with the encoder and publishes them to the sink,
+// each message is keyed by the value of the keyField, or by the scope when the keyField is empty or absent.
+func NewMessageSinkCore(enc zapcore.Encoder, sink MessageSink, enab zapcore.LevelEnabler, keyField string) zapcore.Core {
+	return &messageSinkCore{LevelEnabler: enab, enc: enc, sink: sink, keyField: keyField}
+}
+
+// With impls zapcore.Core.
+func (c *messageSinkCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(clone.enc)
+	}
+
+	if key, ok := c.keyOf(fields); ok {
+		clone.key = key
+	}
+
+	return &clone
+}
+
+// Check impls zapcore.Core.
+func (c *messageSinkCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+
+	return ce
+}
+
+// Write impls zapcore.Core and publishes the encoded entry without the trailing line ending.
+func (c *messageSinkCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(e, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	key, ok := c.keyOf(fields)
+	switch {
+	case ok:
+	case c.key != "":
+		key = c.key
+	default:
+		key = e.LoggerName
+	}
+
+	value := bytes.TrimRight(buf.Bytes(), "\n")
+	if err := c.sink.Publish(context.Background(), []byte(key), append([]byte(nil), value...)); err != nil {
+		return errors.Wrap(err, "failed to publish the entry")
+	}
+
+	return nil
+}
+
+// Sync impls zapcore.Core, the producer owned by the caller is flushed by the caller.
+func (c *messageSinkCore) Sync() error {
+	return nil
+}
+
+// keyOf returns the value of the key field within the fields.
+func (c *messageSinkCore) keyOf(fields []zapcore.Field) (string, bool) {
+	if c.keyField == "" {
+		return "", false
+	}
+
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Key == c.keyField {
+			m := fieldsToMap(fields[i : i+1])
+			return fieldValueString(m[c.keyField]), true
+		}
+	}
+
+	return "", false
+}
+
+// NewMemoryMessageSink returns an in-memory MessageSink for tests.
+func NewMemoryMessageSink() *MemoryMessageSink {
+	return &MemoryMessageSink{}
+}
+
+// Publish impls MessageSink.
+func (s *MemoryMessageSink) Publish(_ context.Context, key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.publishErr != nil {
+		return s.publishErr
+	}
+
+	s.messages = append(s.messages, Message{Key: key, Value: value})
+	return nil
+}
+
+// SetPublishError sets the error returned by the Publish, nil to publish again.
+func (s *MemoryMessageSink) SetPublishError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.publishErr = err
+}
+
+// Messages returns a copy of the published messages.
+func (s *MemoryMessageSink) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+// Reset drops the published messages and the publish error.
+func (s *MemoryMessageSink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = nil
+	s.publishErr = nil
+}
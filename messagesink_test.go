@@ -0,0 +1,68 @@
+package lager
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestMessageSinkCore(t *testing.T) {
+	sink := NewMemoryMessageSink()
+	core := NewMessageSinkCore(zapcore.NewJSONEncoder(defaultEncoderConfig()), sink, zapcore.InfoLevel, "tenant")
+	logger := zap.New(core)
+
+	logger.Named("ads").Info("by scope", zap.Int("count", 1))
+	logger.Named("ads").Info("by field", zap.String("tenant", "a"))
+	logger.With(zap.String("tenant", "b")).Info("by context field")
+	logger.With(zap.String("tenant", "b")).Info("overridden", zap.String("tenant", "c"))
+	logger.Debug("disabled")
+
+	messages := sink.Messages()
+	require.Len(t, messages, 4)
+
+	keys := make([]string, 0, len(messages))
+	for _, m := range messages {
+		keys = append(keys, string(m.Key))
+	}
+	assert.Equal(t, []string{"ads", "a", "b", "c"}, keys)
+
+	var value map[string]interface{}
+	require.NoError(t, json.Unmarshal(messages[0].Value, &value))
+	assert.Equal(t, "by scope", value["msg"])
+	assert.Equal(t, "ads", value["scope"])
+	assert.Equal(t, float64(1), value["count"])
+	assert.NotContains(t, string(messages[0].Value), "\n")
+
+	require.NoError(t, json.Unmarshal(messages[2].Value, &value))
+	assert.Equal(t, "b", value["tenant"])
+
+	sink.SetPublishError(errors.New("broker down"))
+	assert.ErrorContains(t, core.Write(zapcore.Entry{Message: "lost"}, nil), "broker down")
+
+	sink.Reset()
+	assert.Empty(t, sink.Messages())
+}
+
+func TestConfigureMessageSink(t *testing.T) {
+	sink := NewMemoryMessageSink()
+
+	o := DefaultOptions().WithAppID("my-app").WithMessageSink(sink, "")
+	o.OutputPaths = nil
+	o.JSONEncoding = true
+	require.NoError(t, Configure(o))
+
+	zap.L().Named("ads").Info("hello")
+	zap.L().Debug("disabled")
+	require.NoError(t, Configure(DefaultOptions()))
+
+	messages := sink.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "ads", string(messages[0].Key))
+	assert.Contains(t, string(messages[0].Value), `"msg":"hello"`)
+	assert.Contains(t, string(messages[0].Value), `"@app_id":"my-app"`)
+}
@@ -2,8 +2,11 @@ package lager
 
 import (
 	"io"
+	"strings"
+	"time"
 	
-	"github.com/dapings/lager/experiments"
+	"github.com/cockroachdb/errors"
+	"github.com/dapings/lager/common"
 )
 
 const (
@@ -31,21 +34,28 @@ const (
 	
 	// some default log rote infos.
 	defaultRotationMaxAge     = 30
-	defaultRotationMaxSize    = 100 * 1024 * 1024
+	defaultRotationMaxSize    = 100 // megabytes
 	defaultRotationMaxBackups = 1000
 )
 
 type (
-	CloseFunc func() error
+	// CloseFunc closes the resources held by a sink.
+	CloseFunc = common.CloseFunc
+	
+	// Extension plugs a sink into the configured core, see the experiments package for the available ones.
+	Extension = common.Extension
 	
 	// Options the set of options supported by log kit.
 	Options struct {
 		// a list of file system paths to write the log data.
 		// the special value: stdout, stderr, can be used to output the standard I/O stream, default: stdout.
+		// an URL addresses the sink registered for its scheme, see RegisterSink.
 		OutputPaths []string
 		
-		// a list of file system paths to write the error log data.
+		// a list of file system paths to write the error log data, which are the internal errors of the logger,
+		// and the entries at or above the error level by the WithErrorRouting.
 		// the special value: stdout, stderr, can be used to output the standard I/O stream, default: stderr.
+		// an URL addresses the sink registered for its scheme, see RegisterSink.
 		ErrOutputPaths []string
 		
 		// the rotating log file path, this file should be automatically rotated over time
@@ -67,12 +77,23 @@ type (
 		// the maximum old log file number to retain. default at most 1000 log files.
 		RotationMaxBackups int
 		
+		// the rules routing the entries to the outputs besides the OutputPaths, which take the entries
+		// matched by none of the exclusive routes, see the OutputRoute.
+		Routes []OutputRoute
+		
 		// whether the log is formatted as JSON.
 		JSONEncoding bool
 		
 		// whether the log is formatted as XML.
 		XMLEncoding bool
 		
+		// the tick of the sampling, within which the first entries of a message are logged, default 1 second.
+		SamplingTick time.Duration
+		
+		// the interval of the "dropped N messages" summaries of the sampled or rate limited scopes,
+		// default 10 seconds.
+		DropReportInterval time.Duration
+		
 		// capture the grpc logs, default true.
 		// not exposed by the CLI flags, mainly useful for testing.
 		// even though grpc stack is closed, it hold on the logger to cases the data races.
@@ -91,15 +112,295 @@ type (
 		
 		// experimental support
 		// stackdriver
-		useStackdriverFormat bool
-		teeToStackdriver     bool
-		stackdriverLogger    experiments.StackdriverLogger
-		// stackdriverTargetProject string
+		useStackdriverFormat     bool
+		stackdriverTargetProject string
 		// stackdriverLogName       string
 		
+		// the minimum level of the entries recorded as the events of the spans carried by the contexts.
+		spanEventLevel Level
+		
+		// the registered extensions, applied in order to the configured core.
+		extensions []Extension
+		
+		// the openers of the output sinks configured by the options instead of the OutputPaths.
+		outputSinks []func() (Sink, error)
+		
+		// the message queue producers publishing the encoded entries.
+		messageSinks []messageSinkOption
+		
+		// the per-scope sampling and rate limits, can be separated by logLevelSeparator.
+		samplings  string
+		rateLimits string
+		
+		// the redaction policies keyed by the scope names.
+		redactions map[string]*RedactionPolicy
+		
+		// route the entries at or above the error level to the ErrOutputPaths, exclusively or duplicated.
+		routeErrors          bool
+		routeErrorsExclusive bool
+		
+		// the audit log of the audit scope.
+		audit *AuditConfig
+		
+		// collapse the duplicates of the entries, within the window if positive, or else the consecutive ones.
+		dedup       bool
+		dedupWindow time.Duration
+		
 		// tee log to an UDS server
 		teeToUDSServer bool
 		udsSocketAddr  string
 		udsServerPath  string
 	}
-)
\ No newline at end of file
+)
+
+// DefaultOptions returns a new set of options, initialized to the defaults.
+func DefaultOptions() *Options {
+	return &Options{
+		OutputPaths:        []string{DefaultOutputPath},
+		ErrOutputPaths:     []string{DefaultErrOutputPath},
+		RotationMaxSize:    defaultRotationMaxSize,
+		RotationMaxAge:     defaultRotationMaxAge,
+		RotationMaxBackups: defaultRotationMaxBackups,
+		SamplingTick:       defaultSamplingTick,
+		DropReportInterval: defaultDropReportInterval,
+		LogGrpc:            true,
+		appID:              undefinedAppID,
+		outputLevels:       DefaultScopeName + scopeLevelSeparator + defaultOutputLevel.String(),
+		stackTraceLevels:   DefaultScopeName + scopeLevelSeparator + defaultStackTraceLevel.String(),
+		spanEventLevel:     NoneLevel,
+	}
+}
+
+// WithAppID sets the application unique id, which is added to every entry,
+// and used by the sinks identifying the application, e.g. the APP-NAME of the syslog.
+func (o *Options) WithAppID(appID string) *Options {
+	o.appID = appID
+	return o
+}
+
+// WithStackdriverLoggingFormat configures the logs to be written with the structured JSON schema
+// natively ingested by Google Cloud Logging (severity, message, sourceLocation, trace and so on).
+func (o *Options) WithStackdriverLoggingFormat() *Options {
+	o.useStackdriverFormat = true
+	return o
+}
+
+// WithStackdriverTargetProject sets the project of the traces, which the trace correlation fields of the entries
+// are written as the logging.googleapis.com/trace of, e.g. "projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+// so that the entries are shown with their traces by the Cloud Trace.
+// it takes effect with the WithStackdriverLoggingFormat.
+func (o *Options) WithStackdriverTargetProject(projectID string) *Options {
+	o.stackdriverTargetProject = projectID
+	return o
+}
+
+// WithSpanEvents records the entries at or above the level, which are logged by the context-aware API, e.g. the InfoCtx,
+// as the events of the spans carried by the contexts, if recording. the NoneLevel, by default, records none.
+func (o *Options) WithSpanEvents(level Level) *Options {
+	o.spanEventLevel = level
+	return o
+}
+
+// WithExtension registers an extension, which plugs a sink into the configured core,
+// e.g. the experiments.StackdriverExtension to tee the log to stackdriver.
+func (o *Options) WithExtension(e Extension) *Options {
+	o.extensions = append(o.extensions, e)
+	return o
+}
+
+// WithLokiOutput adds the output pushing the log to the Loki push API,
+// which is equivalent to a loki:// URL within the OutputPaths.
+func (o *Options) WithLokiOutput(cfg LokiConfig) *Options {
+	o.outputSinks = append(o.outputSinks, func() (Sink, error) { return NewLokiSink(cfg) })
+	return o
+}
+
+// WithElasticsearchOutput adds the output pushing the log to the Elasticsearch bulk API,
+// which is equivalent to an elasticsearch:// URL within the OutputPaths.
+func (o *Options) WithElasticsearchOutput(cfg ElasticsearchConfig) *Options {
+	o.outputSinks = append(o.outputSinks, func() (Sink, error) { return NewElasticsearchSink(cfg) })
+	return o
+}
+
+// WithMessageSink adds the output publishing the entries encoded by the configured encoder to a message queue,
+// keyed by the value of the keyField, or by the scope when the keyField is empty or absent.
+// the sink is owned by the caller, who flushes and closes the producer.
+func (o *Options) WithMessageSink(sink MessageSink, keyField string) *Options {
+	o.messageSinks = append(o.messageSinks, messageSinkOption{sink: sink, keyField: keyField})
+	return o
+}
+
+// WithRedaction sets the policy masking the sensitive values of the entries of the scope,
+// the policy of the DefaultScopeName applies to the scopes without their own, e.g.
+//
+//	lager.DefaultOptions().WithRedaction(lager.DefaultScopeName, lager.DefaultRedactionPolicy())
+//
+// the entries are redacted before written to any output, including the ones of the extensions, see NewRedactionCore.
+func (o *Options) WithRedaction(scope string, policy *RedactionPolicy) *Options {
+	if o.redactions == nil {
+		o.redactions = make(map[string]*RedactionPolicy)
+	}
+	o.redactions[scope] = policy
+	return o
+}
+
+// WithErrorRouting routes the entries at or above the error level to the ErrOutputPaths,
+// which are also written to the OutputPaths unless exclusive.
+func (o *Options) WithErrorRouting(exclusive bool) *Options {
+	o.routeErrors = true
+	o.routeErrorsExclusive = exclusive
+	return o
+}
+
+// WithOutputRoute adds a route of the entries of some levels, scopes and field values to the outputs of its own, e.g.
+//
+//	lager.DefaultOptions().WithOutputRoute(lager.OutputRoute{
+//		Paths:        []string{"/var/log/audit.log"},
+//		Scopes:       []string{"audit"},
+//		Exclusive:    true,
+//		JSONEncoding: true,
+//		Unsampled:    true,
+//	}).WithOutputRoute(lager.OutputRoute{
+//		Paths:  []string{"/var/log/tenant-x.log"},
+//		Fields: map[string]string{"tenant": "X"},
+//	})
+func (o *Options) WithOutputRoute(route OutputRoute) *Options {
+	o.Routes = append(o.Routes, route)
+	return o
+}
+
+// WithAudit writes the entries of the AuditScope to the audit log, where every entry carries its sequence number,
+// the hash of the previous entry, and its own hash, which is the HMAC-SHA256 of the entry with the key, e.g.
+//
+//	{"level":"info","time":"...","scope":"@audit","msg":"user deleted","seq":42,"prev_hash":"9f86...","hash":"2c26..."}
+//
+// the audit entries are never sampled, rate limited, deduplicated, redacted or written to the other outputs,
+// and each of them is synced to the disk once written. the chain is resumed from the last entry of the audit log,
+// or of its newest rotated file, by the first Configure of the process, continued by the later ones,
+// and is verified by the VerifyAuditLog. the torn last entry of a crash is truncated, and recorded by the next entry.
+func (o *Options) WithAudit(cfg AuditConfig) *Options {
+	o.audit = &cfg
+	return o
+}
+
+// WithDedup collapses the duplicates of the entries, the ones of the same level, scope and message,
+// which are within the window since the first one if the window is positive, or else consecutive, see NewDedupCore.
+// the pending duplicates are written by the next entry, the expiry of the window, the Sync, or the next Configure.
+//
+// the duplicates are told apart regardless of their fields, so the dedup doesn't compose with the routes
+// of the OutputRoute Fields: the duplicates of different field values are collapsed into a single entry,
+// which is routed by the fields of the last duplicate.
+func (o *Options) WithDedup(window time.Duration) *Options {
+	o.dedup = true
+	o.dedupWindow = window
+	return o
+}
+
+// SetOutputLevel sets the minimum log output level for a given scope.
+func (o *Options) SetOutputLevel(scope string, level Level) {
+	o.outputLevels = setLevel(o.outputLevels, scope, level.String())
+}
+
+// GetOutputLevel returns the minimum log output level for a given scope.
+func (o *Options) GetOutputLevel(scope string) (Level, error) {
+	return getLevel(o.outputLevels, scope, defaultOutputLevel)
+}
+
+// SetStackTraceLevel sets the minimum stack tracing level for a given scope.
+func (o *Options) SetStackTraceLevel(scope string, level Level) {
+	o.stackTraceLevels = setLevel(o.stackTraceLevels, scope, level.String())
+}
+
+// GetStackTraceLevel returns the minimum stack tracing level for a given scope.
+func (o *Options) GetStackTraceLevel(scope string) (Level, error) {
+	return getLevel(o.stackTraceLevels, scope, defaultStackTraceLevel)
+}
+
+// SetLogCallers sets whether to output the caller's source code location for a given scope.
+func (o *Options) SetLogCallers(scope string, include bool) {
+	scopes := strings.Split(o.logCallers, logLevelSeparator)
+	kept := make([]string, 0, len(scopes)+1)
+	for _, s := range scopes {
+		if s != "" && s != scope {
+			kept = append(kept, s)
+		}
+	}
+	
+	if include {
+		kept = append(kept, scope)
+	}
+	
+	o.logCallers = strings.Join(kept, logLevelSeparator)
+}
+
+// GetLogCallers returns whether the caller's source code location is output for a given scope.
+func (o *Options) GetLogCallers(scope string) bool {
+	for _, s := range strings.Split(o.logCallers, logLevelSeparator) {
+		if s == scope || s == OverrideScopeName {
+			return true
+		}
+	}
+	
+	return false
+}
+
+// setLevel replaces or appends the "scope:level" pair within the given levels.
+func setLevel(levels, scope, level string) string {
+	pairs := strings.Split(levels, logLevelSeparator)
+	kept := make([]string, 0, len(pairs)+1)
+	for _, p := range pairs {
+		if p == "" {
+			continue
+		}
+		
+		if s, _, ok := strings.Cut(p, scopeLevelSeparator); ok && s == scope {
+			continue
+		}
+		
+		kept = append(kept, p)
+	}
+	
+	kept = append(kept, scope+scopeLevelSeparator+level)
+	return strings.Join(kept, logLevelSeparator)
+}
+
+// getLevel looks up the level of the scope within the given levels.
+// a level without scope applies to the default scope, and the override scope wins over any other one.
+func getLevel(levels, scope string, fallback Level) (Level, error) {
+	l, ok := getScopeValue(levels, scope)
+	if !ok {
+		return fallback, nil
+	}
+	
+	var parsed Level
+	if err := parsed.UnmarshalText([]byte(l)); err != nil {
+		return fallback, errors.Wrapf(err, "invalid level for scope %q", scope)
+	}
+	
+	return parsed, nil
+}
+
+// getScopeValue looks up the value of the scope within the given "scope:value" pairs, e.g. the levels.
+// a value without scope applies to the default scope, and the override scope wins over any other one.
+func getScopeValue(values, scope string) (value string, found bool) {
+	for _, p := range strings.Split(values, logLevelSeparator) {
+		if p == "" {
+			continue
+		}
+		
+		s, v, ok := strings.Cut(p, scopeLevelSeparator)
+		if !ok {
+			s, v = DefaultScopeName, p
+		}
+		
+		if s == OverrideScopeName {
+			return v, true
+		}
+		
+		if s == scope {
+			value, found = v, true
+		}
+	}
+	
+	return value, found
+}
@@ -1 +1,66 @@
 package lager
+
+import (
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionsLevels(t *testing.T) {
+	o := DefaultOptions()
+	
+	lvl, err := o.GetOutputLevel(DefaultScopeName)
+	assert.NoError(t, err)
+	assert.Equal(t, InfoLevel, lvl)
+	
+	o.SetOutputLevel("ads", DebugLevel)
+	o.SetOutputLevel("ads", WarnLevel)
+	lvl, err = o.GetOutputLevel("ads")
+	assert.NoError(t, err)
+	assert.Equal(t, WarnLevel, lvl)
+	assert.Equal(t, "@default:info,ads:warn", o.outputLevels)
+	
+	lvl, err = o.GetOutputLevel("unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultOutputLevel, lvl)
+	
+	o.SetOutputLevel(OverrideScopeName, ErrorLevel)
+	lvl, err = o.GetOutputLevel("ads")
+	assert.NoError(t, err)
+	assert.Equal(t, ErrorLevel, lvl)
+	
+	o.outputLevels = "debug"
+	lvl, err = o.GetOutputLevel(DefaultScopeName)
+	assert.NoError(t, err)
+	assert.Equal(t, DebugLevel, lvl)
+	
+	o.outputLevels = "ads:foo"
+	_, err = o.GetOutputLevel("ads")
+	assert.Error(t, err)
+	
+	lvl, err = o.GetStackTraceLevel(DefaultScopeName)
+	assert.NoError(t, err)
+	assert.Equal(t, NoneLevel, lvl)
+	o.SetStackTraceLevel(DefaultScopeName, ErrorLevel)
+	lvl, err = o.GetStackTraceLevel(DefaultScopeName)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrorLevel, lvl)
+}
+
+func TestOptionsLogCallers(t *testing.T) {
+	o := DefaultOptions()
+	assert.False(t, o.GetLogCallers(DefaultScopeName))
+	
+	o.SetLogCallers(DefaultScopeName, true)
+	o.SetLogCallers("ads", true)
+	o.SetLogCallers("ads", true)
+	assert.True(t, o.GetLogCallers(DefaultScopeName))
+	assert.True(t, o.GetLogCallers("ads"))
+	assert.Equal(t, "@default,ads", o.logCallers)
+	
+	o.SetLogCallers(DefaultScopeName, false)
+	assert.False(t, o.GetLogCallers(DefaultScopeName))
+	
+	o.SetLogCallers(OverrideScopeName, true)
+	assert.True(t, o.GetLogCallers("unknown"))
+}
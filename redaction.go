@@ -0,0 +1,354 @@
+package lager
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// DefaultRedactionMask the mask replacing the redacted values.
+	DefaultRedactionMask = "[REDACTED]"
+)
+
+var (
+	// RedactEmailPattern matches the email addresses.
+	RedactEmailPattern = regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`)
+
+	// RedactCardNumberPattern matches the payment card numbers of 13 to 19 digits,
+	// which may be grouped by the spaces or the dashes, e.g. "4111 1111 1111 1111".
+	RedactCardNumberPattern = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+	// defaultRedactionKeys the keys of the credentials masked by the DefaultRedactionPolicy.
+	defaultRedactionKeys = []string{
+		"password", "passwd", "secret", "token", "access_token", "refresh_token",
+		"authorization", "api_key", "apikey", "cookie", "set-cookie",
+	}
+)
+
+type (
+	// RedactionPolicy the policy masking the sensitive values of the entries.
+	RedactionPolicy struct {
+		// the keys of the fields whose values are masked, matched case-insensitively,
+		// including the keys nested within the objects, e.g. "password".
+		Keys []string
+
+		// the patterns whose matches are masked within the string values and the messages,
+		// e.g. the RedactEmailPattern.
+		Patterns []*regexp.Regexp
+
+		// the types of the values which are masked wherever they are logged,
+		// e.g. reflect.TypeOf(CardNumber("")).
+		Types []reflect.Type
+
+		// the mask replacing the redacted values, the DefaultRedactionMask if empty.
+		Mask string
+	}
+
+	// redactionPolicy the compiled RedactionPolicy.
+	redactionPolicy struct {
+		keys     map[string]struct{}
+		patterns []*regexp.Regexp
+		types    map[reflect.Type]struct{}
+		mask     string
+	}
+
+	// redactionCore the zapcore.Core masking the sensitive values before writing to the wrapped core.
+	// the fields of the With are kept, since the scope of the entries, and so the policy, is unknown until written.
+	redactionCore struct {
+		zapcore.Core
+		policies map[string]*redactionPolicy
+		fields   []zapcore.Field
+		// the wrapped core with the redacted fields of the With per policy, map[*redactionPolicy]zapcore.Core.
+		cores *sync.Map
+	}
+)
+
+// DefaultRedactionPolicy returns a policy masking the common credentials,
+// e.g. the password, token and authorization, the email addresses and the payment card numbers.
+func DefaultRedactionPolicy() *RedactionPolicy {
+	return &RedactionPolicy{
+		Keys:     append([]string(nil), defaultRedactionKeys...),
+		Patterns: []*regexp.Regexp{RedactEmailPattern, RedactCardNumberPattern},
+	}
+}
+
+// compile returns the compiled policy.
+func (p *RedactionPolicy) compile() *redactionPolicy {
+	rp := &redactionPolicy{
+		keys:     make(map[string]struct{}, len(p.Keys)),
+		patterns: append([]*regexp.Regexp(nil), p.Patterns...),
+		types:    make(map[reflect.Type]struct{}, len(p.Types)),
+		mask:     p.Mask,
+	}
+
+	if rp.mask == "" {
+		rp.mask = DefaultRedactionMask
+	}
+
+	for _, k := range p.Keys {
+		rp.keys[strings.ToLower(k)] = struct{}{}
+	}
+
+	for _, t := range p.Types {
+		rp.types[t] = struct{}{}
+	}
+
+	return rp
+}
+
+// NewRedactionCore returns a zapcore.Core masking the sensitive values of the entries by the policies of their scopes,
+// before they are written to the wrapped core, where
+//   - the policies are keyed by the scope names, and the policy of the DefaultScopeName applies to the others,
+//   - the values of the fields are masked by the keys, the types, and the patterns over the string values,
+//   - the patterns are also masked within the messages,
+//   - the errors are written by their messages, whose parts not marked safe by the cockroachdb/redact are masked,
+//     e.g. errors.Newf("user %s", name) is written as "user ×", and errors.Newf("user %s", redact.Safe(name)) as is.
+//
+// the entries of the scopes without policy are written as-is.
+func NewRedactionCore(core zapcore.Core, policies map[string]*RedactionPolicy) zapcore.Core {
+	compiled := make(map[string]*redactionPolicy, len(policies))
+	for scope, p := range policies {
+		if p != nil {
+			compiled[scope] = p.compile()
+		}
+	}
+
+	return &redactionCore{Core: core, policies: compiled, cores: &sync.Map{}}
+}
+
+// policy returns the policy of the scope, or nil if none.
+func (c *redactionCore) policy(scope string) *redactionPolicy {
+	if scope == "" {
+		scope = DefaultScopeName
+	}
+
+	if p, ok := c.policies[scope]; ok {
+		return p
+	}
+
+	return c.policies[DefaultScopeName]
+}
+
+// With impls zapcore.Core.
+func (c *redactionCore) With(fields []zapcore.Field) zapcore.Core {
+	if len(fields) == 0 {
+		return c
+	}
+
+	return &redactionCore{
+		Core:     c.Core,
+		policies: c.policies,
+		fields:   append(append(make([]zapcore.Field, 0, len(c.fields)+len(fields)), c.fields...), fields...),
+		cores:    &sync.Map{},
+	}
+}
+
+// Check impls zapcore.Core.
+func (c *redactionCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+
+	return ce
+}
+
+// Write impls zapcore.Core.
+func (c *redactionCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	p := c.policy(e.LoggerName)
+	if p != nil {
+		e.Message = p.redactString(e.Message)
+		fields = p.redactFields(fields)
+	}
+
+	return writeEnabled(c.policyCore(p), e, fields)
+}
+
+// policyCore returns the wrapped core with the fields of the With redacted by the policy, which may be nil.
+func (c *redactionCore) policyCore(p *redactionPolicy) zapcore.Core {
+	if len(c.fields) == 0 {
+		return c.Core
+	}
+
+	if core, ok := c.cores.Load(p); ok {
+		return core.(zapcore.Core)
+	}
+
+	fields := c.fields
+	if p != nil {
+		fields = p.redactFields(fields)
+	}
+
+	core, _ := c.cores.LoadOrStore(p, c.Core.With(fields))
+	return core.(zapcore.Core)
+}
+
+// redactFields returns the redacted fields, the fields are copied if any is redacted.
+func (p *redactionPolicy) redactFields(fields []zapcore.Field) []zapcore.Field {
+	var redacted []zapcore.Field
+	for i, f := range fields {
+		rf, changed := p.redactField(f)
+		if !changed {
+			if redacted != nil {
+				redacted = append(redacted, f)
+			}
+			continue
+		}
+
+		if redacted == nil {
+			redacted = append(make([]zapcore.Field, 0, len(fields)), fields[:i]...)
+		}
+		redacted = append(redacted, rf)
+	}
+
+	if redacted == nil {
+		return fields
+	}
+
+	return redacted
+}
+
+// redactField returns the redacted field, and whether it's changed.
+func (p *redactionPolicy) redactField(f zapcore.Field) (zapcore.Field, bool) {
+	if f.Type == zapcore.SkipType || f.Type == zapcore.NamespaceType {
+		return f, false
+	}
+
+	if p.maskedKey(f.Key) || p.maskedValue(f.Interface) {
+		return zap.String(f.Key, p.mask), true
+	}
+
+	switch f.Type {
+	case zapcore.StringType:
+		if s := p.redactString(f.String); s != f.String {
+			return zap.String(f.Key, s), true
+		}
+	case zapcore.ByteStringType:
+		if b, ok := f.Interface.([]byte); ok {
+			if s := p.redactString(string(b)); s != string(b) {
+				return zap.String(f.Key, s), true
+			}
+		}
+	case zapcore.StringerType:
+		if s, ok := f.Interface.(fmt.Stringer); ok {
+			return zap.String(f.Key, p.redactString(s.String())), true
+		}
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok {
+			return zap.String(f.Key, p.redactString(errors.Redact(err))), true
+		}
+	case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType:
+		if errs, ok := errorSlice(f.Interface); ok {
+			// the errors of the zap.Errors are written by their redacted messages, like the ones of the zap.Error.
+			msgs := make([]string, 0, len(errs))
+			for _, err := range errs {
+				msgs = append(msgs, p.redactString(errors.Redact(err)))
+			}
+			return zap.Strings(f.Key, msgs), true
+		}
+
+		// the nested values are redacted by marshaling the object into a map.
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		return zap.Any(f.Key, p.redactValue(enc.Fields[f.Key])), true
+	case zapcore.ReflectType:
+		// the nested values are redacted by marshaling the value into a map like the JSON encoder does,
+		// the value failing to marshal is masked, since it can't be redacted.
+		data, err := json.Marshal(f.Interface)
+		if err != nil {
+			return zap.String(f.Key, p.mask), true
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return zap.String(f.Key, p.mask), true
+		}
+		return zap.Any(f.Key, p.redactValue(v)), true
+	}
+
+	return f, false
+}
+
+// redactValue returns the redacted value marshaled by a zapcore.MapObjectEncoder or decoded from JSON.
+func (p *redactionPolicy) redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, nv := range v {
+			if p.maskedKey(k) {
+				m[k] = p.mask
+				continue
+			}
+			m[k] = p.redactValue(nv)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, 0, len(v))
+		for _, nv := range v {
+			a = append(a, p.redactValue(nv))
+		}
+		return a
+	case string:
+		return p.redactString(v)
+	default:
+		if p.maskedValue(v) {
+			return p.mask
+		}
+		return v
+	}
+}
+
+// errorSlice returns the non-nil errors of the value if it's a slice of errors, e.g. the array of the zap.Errors.
+func errorSlice(v interface{}) ([]error, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem() != errorType {
+		return nil, false
+	}
+
+	errs := make([]error, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		if err, ok := rv.Index(i).Interface().(error); ok && err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs, true
+}
+
+// maskedKey reports whether the values of the key are masked.
+func (p *redactionPolicy) maskedKey(key string) bool {
+	if len(p.keys) == 0 {
+		return false
+	}
+
+	_, ok := p.keys[strings.ToLower(key)]
+	return ok
+}
+
+// maskedValue reports whether the value is masked by its type.
+func (p *redactionPolicy) maskedValue(v interface{}) bool {
+	if len(p.types) == 0 || v == nil {
+		return false
+	}
+
+	_, ok := p.types[reflect.TypeOf(v)]
+	return ok
+}
+
+// redactString masks the matches of the patterns within the string.
+func (p *redactionPolicy) redactString(s string) string {
+	for _, re := range p.patterns {
+		s = re.ReplaceAllLiteralString(s, p.mask)
+	}
+
+	return s
+}
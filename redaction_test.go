@@ -0,0 +1,135 @@
+package lager
+
+import (
+	"reflect"
+	"testing"
+	
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/redact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type cardNumber string
+
+type credentials struct {
+	user, password string
+}
+
+func (c credentials) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("user", c.user)
+	enc.AddString("password", c.password)
+	return nil
+}
+
+func TestRedactionCore(t *testing.T) {
+	obs, logs := observer.New(zapcore.InfoLevel)
+	policy := DefaultRedactionPolicy()
+	policy.Types = []reflect.Type{reflect.TypeOf(cardNumber(""))}
+	logger := zap.New(NewRedactionCore(obs, map[string]*RedactionPolicy{DefaultScopeName: policy}))
+	
+	logger.With(zap.String("Authorization", "Bearer abc")).Info("sent to bob@example.com",
+		zap.String("password", "hunter2"),
+		zap.String("note", "card 4111 1111 1111 1111 of bob@example.com"),
+		zap.Any("card", cardNumber("4111")),
+		zap.Object("login", credentials{user: "bob", password: "hunter2"}),
+		zap.Error(errors.Newf("user %s not found, %s", "bob", redact.Safe("retrying"))),
+		zap.Int("count", 1))
+	logger.Debug("disabled", zap.String("password", "hunter2"))
+	
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "sent to [REDACTED]", entries[0].Message)
+	assert.Equal(t, map[string]interface{}{
+		"Authorization": DefaultRedactionMask,
+		"password":      DefaultRedactionMask,
+		"note":          "card [REDACTED] of [REDACTED]",
+		"card":          DefaultRedactionMask,
+		"login":         map[string]interface{}{"user": "bob", "password": DefaultRedactionMask},
+		"error":         "user × not found, retrying",
+		"count":         int64(1),
+	}, entries[0].ContextMap())
+}
+
+func TestRedactionCoreNestedValues(t *testing.T) {
+	type request struct {
+		User  string `json:"user"`
+		Token string `json:"token"`
+	}
+	
+	obs, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(NewRedactionCore(obs, map[string]*RedactionPolicy{DefaultScopeName: DefaultRedactionPolicy()}))
+	
+	logger.Info("nested",
+		zap.Any("headers", map[string]string{"password": "hunter2", "host": "example.com"}),
+		zap.Reflect("req", request{User: "bob", Token: "abc"}),
+		zap.Reflect("chan", make(chan int)),
+		zap.Errors("errors", []error{errors.Newf("user %s not found", "bob"), nil}))
+	
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 1)
+	assert.Equal(t, map[string]interface{}{
+		"headers": map[string]interface{}{"password": DefaultRedactionMask, "host": "example.com"},
+		"req":     map[string]interface{}{"user": "bob", "token": DefaultRedactionMask},
+		"chan":    DefaultRedactionMask,
+		"errors":  []interface{}{"user × not found"},
+	}, entries[0].ContextMap())
+}
+
+func TestRedactionCorePerScope(t *testing.T) {
+	obs, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(NewRedactionCore(obs, map[string]*RedactionPolicy{
+		"auth": {Keys: []string{"token"}, Mask: "***"},
+	})).With(zap.String("token", "abc"))
+	
+	logger.Named("auth").Info("masked")
+	logger.Named("ads").Info("as-is")
+	logger.Info("as-is")
+	
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 3)
+	assert.Equal(t, "***", entries[0].ContextMap()["token"])
+	assert.Equal(t, "abc", entries[1].ContextMap()["token"])
+	assert.Equal(t, "abc", entries[2].ContextMap()["token"])
+}
+
+func TestRedactionCoreHonorsWrappedLevels(t *testing.T) {
+	info, infoLogs := observer.New(zapcore.InfoLevel)
+	debug, debugLogs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(NewRedactionCore(zapcore.NewTee(info, debug), map[string]*RedactionPolicy{
+		DefaultScopeName: DefaultRedactionPolicy(),
+	}))
+	
+	logger.Debug("debug")
+	logger.Info("info")
+	assert.Equal(t, 1, infoLogs.Len())
+	assert.Equal(t, 2, debugLogs.Len())
+}
+
+func TestRedactionCoreWriteErrors(t *testing.T) {
+	core := NewRedactionCore(zapcore.NewCore(zapcore.NewJSONEncoder(defaultEncoderConfig()), failingWriter{}, zapcore.DebugLevel),
+		map[string]*RedactionPolicy{DefaultScopeName: DefaultRedactionPolicy()})
+	
+	err := core.Write(zapcore.Entry{Level: zapcore.InfoLevel, Message: "lost"}, nil)
+	assert.ErrorIs(t, err, errDiskFull, "expected the errors of the wrapped core returned")
+}
+
+func TestConfigureRedaction(t *testing.T) {
+	s := RegisterScope("test-redaction", "")
+	buf := configureBuffer(t, DefaultOptions().
+		WithRedaction(DefaultScopeName, DefaultRedactionPolicy()).
+		WithRedaction("test-redaction", &RedactionPolicy{Keys: []string{"user"}}))
+	
+	s.Info("scope", zap.String("user", "bob"), zap.String("password", "hunter2"))
+	zap.L().Info("global", zap.String("user", "bob"), zap.String("password", "hunter2"))
+	
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 2)
+	assert.Equal(t, DefaultRedactionMask, entries[0]["user"])
+	assert.Equal(t, "hunter2", entries[0]["password"])
+	assert.Equal(t, "bob", entries[1]["user"])
+	assert.Equal(t, DefaultRedactionMask, entries[1]["password"])
+}
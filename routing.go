@@ -0,0 +1,324 @@
+package lager
+
+import (
+	"sync/atomic"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+type (
+	// OutputRoute a rule routing the entries of some levels, scopes and field values to the outputs of its own, e.g.
+	// the scope "audit" to the "/var/log/audit.log" in JSON and never sampled, or the field tenant=X to a separate file.
+	// an entry is routed by every route it matches.
+	OutputRoute struct {
+		// a list of the output paths, like the OutputPaths.
+		Paths []string
+
+		// the levels routed, e.g. the LevelRange(ErrorLevel, FatalLevel), all levels if nil.
+		Levels LevelEnabler
+
+		// the scopes routed, all scopes if empty.
+		Scopes []string
+
+		// the field values routed, matched against the text of the values, e.g. {"tenant": "X"},
+		// including the fields carried by the loggers, all values if empty.
+		// the duplicates collapsed by the WithDedup are routed by the fields of the last one, see the WithDedup.
+		Fields map[string]string
+
+		// whether the routed entries are taken from the OutputPaths and the other outputs of the options,
+		// or else duplicated to the outputs of the route.
+		Exclusive bool
+
+		// whether the route is a fallback, which routes the entries only if matched by none of the other routes.
+		Fallback bool
+
+		// whether the outputs of the route are formatted as JSON, regardless of the encoding of the options.
+		JSONEncoding bool
+
+		// whether the entries of the levels and the scopes of the route are never sampled or rate limited,
+		// regardless of its fields, since the limits are decided before the fields are known.
+		Unsampled bool
+	}
+
+	// outputRouteMatcher matches the entries of an OutputRoute.
+	outputRouteMatcher struct {
+		levels    LevelEnabler
+		scopes    map[string]struct{}
+		fields    map[string]string
+		exclusive bool
+		fallback  bool
+	}
+
+	// outputRouteCore the matcher of a route and the core writing its outputs.
+	outputRouteCore struct {
+		route *outputRouteMatcher
+		core  zapcore.Core
+	}
+
+	// routingCore writes the entries to the cores of the routes they match,
+	// and to the core of the other outputs unless taken by an exclusive route.
+	routingCore struct {
+		core   zapcore.Core
+		routes []outputRouteCore
+		// the fields of the With, which are matched against the field values of the routes.
+		fields []zapcore.Field
+	}
+)
+
+var (
+	// zapToLevel maps the zap levels to the log levels, the levels above the error level are the fatal level.
+	zapToLevel = map[zapcore.Level]Level{
+		zapcore.DebugLevel:  DebugLevel,
+		zapcore.InfoLevel:   InfoLevel,
+		zapcore.WarnLevel:   WarnLevel,
+		zapcore.ErrorLevel:  ErrorLevel,
+		zapcore.DPanicLevel: FatalLevel,
+		zapcore.PanicLevel:  FatalLevel,
+		zapcore.FatalLevel:  FatalLevel,
+	}
+
+	// unsampledRoutes the *[]*outputRouteMatcher of the unsampled routes of the latest Configure.
+	unsampledRoutes atomic.Pointer[[]*outputRouteMatcher]
+)
+
+// LevelRange returns the LevelEnabler of the levels from the least severe one to the most severe one, inclusive,
+// e.g. the LevelRange(DebugLevel, WarnLevel) enables the debug, info and warn levels.
+func LevelRange(lowest, highest Level) LevelEnablerFunc {
+	return func(l Level) bool {
+		return l != NoneLevel && l <= lowest && l >= highest
+	}
+}
+
+// newOutputRouteMatcher returns the matcher of the route.
+func newOutputRouteMatcher(route OutputRoute) *outputRouteMatcher {
+	m := &outputRouteMatcher{levels: route.Levels, exclusive: route.Exclusive, fallback: route.Fallback}
+	if len(route.Scopes) > 0 {
+		m.scopes = make(map[string]struct{}, len(route.Scopes))
+		for _, s := range route.Scopes {
+			m.scopes[s] = struct{}{}
+		}
+	}
+
+	if len(route.Fields) > 0 {
+		m.fields = make(map[string]string, len(route.Fields))
+		for k, v := range route.Fields {
+			m.fields[k] = v
+		}
+	}
+
+	return m
+}
+
+// matchesEntry reports whether the level and the scope of the entry are routed,
+// the entries without scope are of the default scope.
+func (m *outputRouteMatcher) matchesEntry(e zapcore.Entry) bool {
+	if m.levels != nil && !m.levels.Enabled(zapToLevel[e.Level]) {
+		return false
+	}
+
+	if m.scopes == nil {
+		return true
+	}
+
+	scope := e.LoggerName
+	if scope == "" {
+		scope = DefaultScopeName
+	}
+
+	_, ok := m.scopes[scope]
+	return ok
+}
+
+// matchesFields reports whether the field values are routed, the values are looked up by the given function.
+func (m *outputRouteMatcher) matchesFields(values func() map[string]interface{}) bool {
+	if len(m.fields) == 0 {
+		return true
+	}
+
+	all := values()
+	for k, want := range m.fields {
+		v, ok := all[k]
+		if !ok || fieldValueString(v) != want {
+			return false
+		}
+	}
+
+	return true
+}
+
+// unsampled reports whether the entry is exempted from the limits by an unsampled route.
+func unsampled(e zapcore.Entry) bool {
+	routes := unsampledRoutes.Load()
+	if routes == nil {
+		return false
+	}
+
+	for _, r := range *routes {
+		if r.matchesEntry(e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Enabled impls zapcore.Core.
+func (c *routingCore) Enabled(l zapcore.Level) bool {
+	if c.core.Enabled(l) {
+		return true
+	}
+
+	for _, r := range c.routes {
+		if r.core.Enabled(l) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// With impls zapcore.Core.
+func (c *routingCore) With(fields []zapcore.Field) zapcore.Core {
+	routes := make([]outputRouteCore, 0, len(c.routes))
+	for _, r := range c.routes {
+		routes = append(routes, outputRouteCore{route: r.route, core: r.core.With(fields)})
+	}
+
+	return &routingCore{
+		core:   c.core.With(fields),
+		routes: routes,
+		fields: append(append(make([]zapcore.Field, 0, len(c.fields)+len(fields)), c.fields...), fields...),
+	}
+}
+
+// Check impls zapcore.Core.
+func (c *routingCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+
+	return ce
+}
+
+// Write impls zapcore.Core.
+func (c *routingCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	var values map[string]interface{}
+	lookup := func() map[string]interface{} {
+		if values == nil {
+			values = fieldsToMap(append(append(make([]zapcore.Field, 0, len(c.fields)+len(fields)), c.fields...), fields...))
+		}
+		return values
+	}
+
+	var (
+		err            error
+		matched, taken bool
+	)
+
+	write := func(r outputRouteCore) {
+		if !r.route.matchesEntry(e) || !r.route.matchesFields(lookup) {
+			return
+		}
+
+		matched, taken = true, taken || r.route.exclusive
+		if r.core.Enabled(e.Level) {
+			err = errors.CombineErrors(err, r.core.Write(e, fields))
+		}
+	}
+
+	for _, r := range c.routes {
+		if !r.route.fallback {
+			write(r)
+		}
+	}
+
+	if !matched {
+		for _, r := range c.routes {
+			if r.route.fallback {
+				write(r)
+			}
+		}
+	}
+
+	if !taken && c.core.Enabled(e.Level) {
+		err = errors.CombineErrors(err, c.core.Write(e, fields))
+	}
+
+	return err
+}
+
+// Sync impls zapcore.Core.
+func (c *routingCore) Sync() error {
+	err := c.core.Sync()
+	for _, r := range c.routes {
+		err = errors.CombineErrors(err, r.core.Sync())
+	}
+
+	return err
+}
+
+// routeOutputs returns the core writing the entries to the routes of the options besides the given core
+// of the other outputs, and the closers of the outputs of the routes.
+// the error routing writes to the given error output sink and entry sinks, which are opened from the ErrOutputPaths.
+func routeOutputs(core zapcore.Core, enc zapcore.Encoder, errSink zapcore.WriteSyncer, errEntrySinks []EntrySink,
+	options *Options) (zapcore.Core, []CloseFunc, error) {
+	var (
+		closers []CloseFunc
+		routes  []outputRouteCore
+	)
+
+	if options.routeErrors {
+		cores := []zapcore.Core{zapcore.NewCore(enc.Clone(), errSink, zapcore.DebugLevel)}
+		for _, es := range errEntrySinks {
+			cores = append(cores, newEntrySinkCore(zapcore.DebugLevel, es))
+		}
+		routes = append(routes, outputRouteCore{
+			route: newOutputRouteMatcher(OutputRoute{Levels: LevelRange(ErrorLevel, FatalLevel), Exclusive: options.routeErrorsExclusive}),
+			core:  zapcore.NewTee(cores...),
+		})
+	}
+
+	for _, route := range options.Routes {
+		if len(route.Paths) == 0 {
+			_ = closeAll(closers)
+			return nil, nil, errors.New("no output paths of the route")
+		}
+
+		sink, entrySinks, closeOut, err := openSinks(route.Paths...)
+		if err != nil {
+			_ = closeAll(closers)
+			return nil, nil, errors.Wrap(err, "failed to open the output paths of the route")
+		}
+		closers = append(closers, closeOut)
+
+		routeEnc := enc.Clone()
+		if route.JSONEncoding {
+			routeEnc = zapcore.NewJSONEncoder(defaultEncoderConfig())
+		}
+
+		cores := []zapcore.Core{zapcore.NewCore(routeEnc, sink, zapcore.DebugLevel)}
+		for _, es := range entrySinks {
+			cores = append(cores, newEntrySinkCore(zapcore.DebugLevel, es))
+		}
+		routes = append(routes, outputRouteCore{route: newOutputRouteMatcher(route), core: zapcore.NewTee(cores...)})
+	}
+
+	if len(routes) == 0 {
+		return core, nil, nil
+	}
+
+	return &routingCore{core: core, routes: routes}, closers, nil
+}
+
+// unsampledRouteMatchers returns the matchers of the unsampled routes of the options.
+func unsampledRouteMatchers(options *Options) []*outputRouteMatcher {
+	var matchers []*outputRouteMatcher
+	for _, route := range options.Routes {
+		if route.Unsampled {
+			matchers = append(matchers, newOutputRouteMatcher(route))
+		}
+	}
+
+	return matchers
+}
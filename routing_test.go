@@ -0,0 +1,139 @@
+package lager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// readLines decodes the JSON lines of the file.
+func readLines(t *testing.T, path string) []map[string]interface{} {
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	
+	return decodeLines(t, bytes.NewBuffer(b))
+}
+
+func TestLevelRange(t *testing.T) {
+	r := LevelRange(InfoLevel, ErrorLevel)
+	assert.False(t, r.Enabled(DebugLevel))
+	assert.True(t, r.Enabled(InfoLevel))
+	assert.True(t, r.Enabled(WarnLevel))
+	assert.True(t, r.Enabled(ErrorLevel))
+	assert.False(t, r.Enabled(FatalLevel))
+	assert.False(t, LevelRange(DebugLevel, FatalLevel).Enabled(NoneLevel))
+}
+
+func TestErrorRouting(t *testing.T) {
+	for _, exclusive := range []bool{false, true} {
+		errPath := filepath.Join(t.TempDir(), "err.log")
+		o := DefaultOptions().WithErrorRouting(exclusive)
+		o.ErrOutputPaths = []string{errPath}
+		buf := configureBuffer(t, o)
+		
+		zap.L().Info("info")
+		zap.L().Warn("warn")
+		zap.L().Error("error")
+		require.NoError(t, Configure(DefaultOptions()))
+		
+		errs := readLines(t, errPath)
+		require.Len(t, errs, 1)
+		assert.Equal(t, "error", errs[0]["msg"])
+		
+		if exclusive {
+			assert.Len(t, decodeLines(t, buf), 2, "expected the errors taken from the output paths")
+		} else {
+			assert.Len(t, decodeLines(t, buf), 3, "expected the errors duplicated")
+		}
+	}
+}
+
+func TestOutputRoute(t *testing.T) {
+	s := RegisterScope("test-route", "")
+	dir := t.TempDir()
+	auditPath, warnPath := filepath.Join(dir, "audit.log"), filepath.Join(dir, "warn.log")
+	
+	o := DefaultOptions().
+		WithOutputRoute(OutputRoute{Paths: []string{auditPath}, Scopes: []string{"test-route"}, Exclusive: true}).
+		WithOutputRoute(OutputRoute{Paths: []string{warnPath}, Levels: LevelRange(WarnLevel, WarnLevel)})
+	buf := configureBuffer(t, o)
+	
+	s.Info("audited")
+	s.Warn("audited warn")
+	zap.L().Info("default")
+	zap.L().Warn("default warn")
+	require.NoError(t, Configure(DefaultOptions()))
+	
+	audit := readLines(t, auditPath)
+	require.Len(t, audit, 2)
+	assert.Equal(t, "audited", audit[0]["msg"])
+	assert.Equal(t, "test-route", audit[0]["scope"])
+	
+	warns := readLines(t, warnPath)
+	require.Len(t, warns, 2)
+	assert.Equal(t, "audited warn", warns[0]["msg"])
+	assert.Equal(t, "default warn", warns[1]["msg"])
+	
+	main := decodeLines(t, buf)
+	require.Len(t, main, 2, "expected the entries of the exclusive route taken from the output paths")
+	assert.Equal(t, "default", main[0]["msg"])
+	
+	assert.Error(t, Configure(DefaultOptions().WithOutputRoute(OutputRoute{Scopes: []string{"ads"}})))
+}
+
+func TestOutputRouteFields(t *testing.T) {
+	dir := t.TempDir()
+	tenantPath, fallbackPath := filepath.Join(dir, "tenant-x.log"), filepath.Join(dir, "fallback.log")
+	
+	o := DefaultOptions().
+		WithOutputRoute(OutputRoute{Paths: []string{tenantPath}, Fields: map[string]string{"tenant": "x", "count": "1"}, Exclusive: true}).
+		WithOutputRoute(OutputRoute{Paths: []string{fallbackPath}, Levels: LevelRange(InfoLevel, FatalLevel), Fallback: true})
+	buf := configureBuffer(t, o)
+	
+	zap.L().Info("field", zap.String("tenant", "x"), zap.Int("count", 1))
+	zap.L().With(zap.String("tenant", "x")).Info("context field", zap.Int("count", 1))
+	zap.L().Info("other tenant", zap.String("tenant", "y"), zap.Int("count", 1))
+	zap.L().Debug("not in the fallback levels")
+	require.NoError(t, Configure(DefaultOptions()))
+	
+	tenant := readLines(t, tenantPath)
+	require.Len(t, tenant, 2)
+	assert.Equal(t, "field", tenant[0]["msg"])
+	assert.Equal(t, "context field", tenant[1]["msg"])
+	
+	fallback := readLines(t, fallbackPath)
+	require.Len(t, fallback, 1)
+	assert.Equal(t, "other tenant", fallback[0]["msg"])
+	
+	main := decodeLines(t, buf)
+	require.Len(t, main, 1)
+	assert.Equal(t, "other tenant", main[0]["msg"])
+}
+
+func TestOutputRouteEncodingAndSampling(t *testing.T) {
+	s := RegisterScope("test-route-audit", "")
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	
+	o := DefaultOptions().
+		WithSampling("1/0").
+		WithOutputRoute(OutputRoute{Paths: []string{auditPath}, Scopes: []string{"test-route-audit"}, JSONEncoding: true, Unsampled: true})
+	o.OutputPaths = nil
+	o.DropReportInterval = time.Hour
+	require.NoError(t, Configure(o))
+	t.Cleanup(func() { _ = Configure(DefaultOptions()) })
+	
+	for i := 0; i < 3; i++ {
+		s.Info("audited", zap.Int("i", i))
+	}
+	require.NoError(t, Configure(DefaultOptions()))
+	
+	audit := readLines(t, auditPath)
+	require.Len(t, audit, 3, "expected the entries of the route never sampled")
+	assert.Equal(t, float64(2), audit[2]["i"])
+}
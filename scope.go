@@ -0,0 +1,435 @@
+package lager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	uatomic "go.uber.org/atomic"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type (
+	// Scope a logging scope, which has its own output level, stack trace level and caller annotation,
+	// the scopes are registered by the RegisterScope and configured by the Configure, e.g.
+	//
+	//	var adsScope = lager.RegisterScope("ads", "the ads debugging")
+	//
+	//	adsScope.Info("pushed", zap.Int("count", 3))
+	Scope struct {
+		name        string
+		description string
+		callerSkip  int
+
+		// the state shared by the scope and its copies, e.g. the WithCallerSkip.
+		outputLevel     AtomicLevel
+		stackTraceLevel AtomicLevel
+		logCallers      *uatomic.Bool
+		limits          *atomic.Pointer[scopeLimits]
+
+		// the zap logger built from the configured core.
+		logger atomic.Pointer[scopeLogger]
+	}
+
+	// scopeLogger the zap logger of a scope built from a base and the caller annotation.
+	scopeLogger struct {
+		base    *zapBase
+		callers bool
+		limits  *scopeLimits
+		logger  *zap.Logger
+	}
+
+	// zapBase the core and the error output configured by the Configure,
+	// the core enables all levels, which are gated by the scopes.
+	zapBase struct {
+		core    zapcore.Core
+		errSink zapcore.WriteSyncer
+	}
+
+	// levelCore gates a core by a level enabler.
+	levelCore struct {
+		zapcore.Core
+		enab zapcore.LevelEnabler
+	}
+)
+
+var (
+	scopesMu sync.RWMutex
+	scopes   = map[string]*Scope{}
+
+	// base the *zapBase of the latest Configure, nothing is written until the Configure.
+	base atomic.Pointer[zapBase]
+
+	defaultScope = RegisterScope(DefaultScopeName, "Unscoped logging messages.")
+)
+
+func init() {
+	base.Store(&zapBase{core: zapcore.NewNopCore(), errSink: zapcore.Lock(os.Stderr)})
+}
+
+// RegisterScope registers a new logging scope, or returns the registered one of the name.
+// the name must not contain the ':' and ',' separating the levels of the options,
+// and the new scope starts with the levels and the caller annotation of the default scope.
+func RegisterScope(name, description string) *Scope {
+	if name == "" || strings.ContainsAny(name, scopeLevelSeparator+logLevelSeparator) {
+		panic(errors.Errorf("invalid scope name: %q", name))
+	}
+
+	scopesMu.Lock()
+	defer scopesMu.Unlock()
+
+	if s, ok := scopes[name]; ok {
+		return s
+	}
+
+	s := &Scope{
+		name:            name,
+		description:     description,
+		outputLevel:     NewAtomicLevelAt(defaultOutputLevel),
+		stackTraceLevel: NewAtomicLevelAt(defaultStackTraceLevel),
+		logCallers:      uatomic.NewBool(false),
+		limits:          &atomic.Pointer[scopeLimits]{},
+	}
+
+	if d, ok := scopes[DefaultScopeName]; ok {
+		s.outputLevel.SetLevel(d.GetOutputLevel())
+		s.stackTraceLevel.SetLevel(d.GetStackTraceLevel())
+		s.logCallers.Store(d.GetLogCallers())
+		if l := d.limits.Load(); l != nil {
+			s.limits.Store(newScopeLimits(l.cfg, nil))
+		}
+	}
+
+	scopes[name] = s
+	return s
+}
+
+// FindScope returns the registered scope of the name, or nil if none.
+func FindScope(name string) *Scope {
+	scopesMu.RLock()
+	defer scopesMu.RUnlock()
+
+	return scopes[name]
+}
+
+// Scopes returns a snapshot of the registered scopes keyed by their names.
+func Scopes() map[string]*Scope {
+	scopesMu.RLock()
+	defer scopesMu.RUnlock()
+
+	m := make(map[string]*Scope, len(scopes))
+	for k, v := range scopes {
+		m[k] = v
+	}
+
+	return m
+}
+
+// sortedScopes returns the registered scopes sorted by their names.
+func sortedScopes() []*Scope {
+	all := Scopes()
+	sorted := make([]*Scope, 0, len(all))
+	for _, s := range all {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+
+	return sorted
+}
+
+// Name returns the name of the scope.
+func (s *Scope) Name() string {
+	return s.name
+}
+
+// Description returns the description of the scope.
+func (s *Scope) Description() string {
+	return s.description
+}
+
+// SetOutputLevel sets the minimum log output level of the scope.
+func (s *Scope) SetOutputLevel(l Level) {
+	s.outputLevel.SetLevel(l)
+}
+
+// GetOutputLevel returns the minimum log output level of the scope.
+func (s *Scope) GetOutputLevel() Level {
+	return s.outputLevel.Level()
+}
+
+// SetStackTraceLevel sets the minimum stack tracing level of the scope.
+func (s *Scope) SetStackTraceLevel(l Level) {
+	s.stackTraceLevel.SetLevel(l)
+}
+
+// GetStackTraceLevel returns the minimum stack tracing level of the scope.
+func (s *Scope) GetStackTraceLevel() Level {
+	return s.stackTraceLevel.Level()
+}
+
+// SetLogCallers sets whether to output the caller's source code location of the scope.
+func (s *Scope) SetLogCallers(include bool) {
+	s.logCallers.Store(include)
+}
+
+// GetLogCallers returns whether the caller's source code location is output of the scope.
+func (s *Scope) GetLogCallers() bool {
+	return s.logCallers.Load()
+}
+
+// Enabled returns whether the level is enabled by the scope.
+func (s *Scope) Enabled(l Level) bool {
+	return s.outputLevel.Enabled(l)
+}
+
+// DebugEnabled returns whether the debug level is enabled by the scope.
+func (s *Scope) DebugEnabled() bool {
+	return s.Enabled(DebugLevel)
+}
+
+// InfoEnabled returns whether the info level is enabled by the scope.
+func (s *Scope) InfoEnabled() bool {
+	return s.Enabled(InfoLevel)
+}
+
+// WarnEnabled returns whether the warn level is enabled by the scope.
+func (s *Scope) WarnEnabled() bool {
+	return s.Enabled(WarnLevel)
+}
+
+// ErrorEnabled returns whether the error level is enabled by the scope.
+func (s *Scope) ErrorEnabled() bool {
+	return s.Enabled(ErrorLevel)
+}
+
+// WithCallerSkip returns a copy of the scope, whose caller annotation skips the additional callers,
+// e.g. a wrapper of the scope skips itself. the copy shares the levels with the scope.
+func (s *Scope) WithCallerSkip(skip int) *Scope {
+	return &Scope{
+		name:            s.name,
+		description:     s.description,
+		callerSkip:      s.callerSkip + skip,
+		outputLevel:     s.outputLevel,
+		stackTraceLevel: s.stackTraceLevel,
+		logCallers:      s.logCallers,
+		limits:          s.limits,
+	}
+}
+
+// Named returns a child of the scope named by the name joined to the scope name by a dot, e.g. "ads.client",
+// which isn't registered, and shares the levels, the caller annotation and the limits with the scope.
+// the ':' and ',' of the name are replaced by the '_', so that the name stays a valid scope name.
+func (s *Scope) Named(name string) *Scope {
+	c := s.WithCallerSkip(0)
+	c.name = s.name + "." + strings.NewReplacer(scopeLevelSeparator, "_", logLevelSeparator, "_").Replace(name)
+
+	return c
+}
+
+// Debug outputs a message at the debug level.
+func (s *Scope) Debug(msg string, fields ...zap.Field) {
+	s.log(DebugLevel, msg, fields)
+}
+
+// Debugf uses fmt.Sprintf to construct and output a message at the debug level.
+func (s *Scope) Debugf(template string, args ...interface{}) {
+	if s.DebugEnabled() {
+		s.log(DebugLevel, fmt.Sprintf(template, args...), nil)
+	}
+}
+
+// Info outputs a message at the info level.
+func (s *Scope) Info(msg string, fields ...zap.Field) {
+	s.log(InfoLevel, msg, fields)
+}
+
+// Infof uses fmt.Sprintf to construct and output a message at the info level.
+func (s *Scope) Infof(template string, args ...interface{}) {
+	if s.InfoEnabled() {
+		s.log(InfoLevel, fmt.Sprintf(template, args...), nil)
+	}
+}
+
+// Warn outputs a message at the warn level.
+func (s *Scope) Warn(msg string, fields ...zap.Field) {
+	s.log(WarnLevel, msg, fields)
+}
+
+// Warnf uses fmt.Sprintf to construct and output a message at the warn level.
+func (s *Scope) Warnf(template string, args ...interface{}) {
+	if s.WarnEnabled() {
+		s.log(WarnLevel, fmt.Sprintf(template, args...), nil)
+	}
+}
+
+// Error outputs a message at the error level.
+func (s *Scope) Error(msg string, fields ...zap.Field) {
+	s.log(ErrorLevel, msg, fields)
+}
+
+// Errorf uses fmt.Sprintf to construct and output a message at the error level.
+func (s *Scope) Errorf(template string, args ...interface{}) {
+	if s.ErrorEnabled() {
+		s.log(ErrorLevel, fmt.Sprintf(template, args...), nil)
+	}
+}
+
+// Fatal outputs a message at the fatal level, then the process exits.
+func (s *Scope) Fatal(msg string, fields ...zap.Field) {
+	s.log(FatalLevel, msg, fields)
+}
+
+// Fatalf uses fmt.Sprintf to construct and output a message at the fatal level, then the process exits.
+func (s *Scope) Fatalf(template string, args ...interface{}) {
+	s.log(FatalLevel, fmt.Sprintf(template, args...), nil)
+}
+
+// DebugCtx outputs a message at the debug level with the fields carried by the context, see WithContext,
+// the trace correlation fields of the span carried by the context are added,
+// and the entry is recorded as a span event at or above the level of the Options WithSpanEvents.
+func (s *Scope) DebugCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	if s.DebugEnabled() {
+		s.log(DebugLevel, msg, withContextFields(ctx, fields))
+		recordSpanEvent(ctx, s.name, DebugLevel, msg, fields)
+	}
+}
+
+// InfoCtx outputs a message at the info level with the fields carried by the context, see WithContext.
+func (s *Scope) InfoCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	if s.InfoEnabled() {
+		s.log(InfoLevel, msg, withContextFields(ctx, fields))
+		recordSpanEvent(ctx, s.name, InfoLevel, msg, fields)
+	}
+}
+
+// WarnCtx outputs a message at the warn level with the fields carried by the context, see WithContext.
+func (s *Scope) WarnCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	if s.WarnEnabled() {
+		s.log(WarnLevel, msg, withContextFields(ctx, fields))
+		recordSpanEvent(ctx, s.name, WarnLevel, msg, fields)
+	}
+}
+
+// ErrorCtx outputs a message at the error level with the fields carried by the context, see WithContext.
+func (s *Scope) ErrorCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	if s.ErrorEnabled() {
+		s.log(ErrorLevel, msg, withContextFields(ctx, fields))
+		recordSpanEvent(ctx, s.name, ErrorLevel, msg, fields)
+	}
+}
+
+// FatalCtx outputs a message at the fatal level with the fields carried by the context, then the process exits.
+func (s *Scope) FatalCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	recordSpanEvent(ctx, s.name, FatalLevel, msg, fields)
+	s.log(FatalLevel, msg, withContextFields(ctx, fields))
+}
+
+// Log outputs a message at the level, which is the building block of the adapters, e.g. the logr sink.
+func (s *Scope) Log(level Level, msg string, fields ...zap.Field) {
+	s.log(level, msg, fields)
+}
+
+// log writes the entry unless the level is disabled by the scope,
+// the fatal level is always written, so that the process exits.
+func (s *Scope) log(level Level, msg string, fields []zap.Field) {
+	if level != FatalLevel && !s.Enabled(level) {
+		return
+	}
+
+	if ce := s.zapLogger().Check(levelToZap[level], msg); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// logRecord writes the entry of a record whose time and call site are known, e.g. a slog.Record,
+// the zero time and pc are filled in by the zap.
+func (s *Scope) logRecord(level Level, t time.Time, pc uintptr, msg string, fields []zap.Field) {
+	if level != FatalLevel && !s.Enabled(level) {
+		return
+	}
+
+	ce := s.zapLogger().Check(levelToZap[level], msg)
+	if ce == nil {
+		return
+	}
+
+	if !t.IsZero() {
+		ce.Time = t
+	}
+
+	if ce.Caller.Defined && pc != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		ce.Caller = zapcore.NewEntryCaller(pc, frame.File, frame.Line, true)
+		ce.Caller.Function = frame.Function
+	}
+
+	ce.Write(fields...)
+}
+
+// zapLogger returns the zap logger of the scope, which is rebuilt once the Configure or the caller annotation changes.
+func (s *Scope) zapLogger() *zap.Logger {
+	b := base.Load()
+	callers := s.logCallers.Load()
+	limits := s.limits.Load()
+	if l := s.logger.Load(); l != nil && l.base == b && l.callers == callers && l.limits == limits {
+		return l.logger
+	}
+
+	core := b.core
+	if limits != nil {
+		core = limits.wrap(b)
+	}
+
+	logger := zap.New(core,
+		zap.ErrorOutput(b.errSink),
+		zap.WithCaller(callers),
+		// the callers are the log and the logging method of the scope.
+		zap.AddCallerSkip(s.callerSkip+2),
+		zap.AddStacktrace(zapEnabler(s.stackTraceLevel)))
+	if s.name != DefaultScopeName {
+		logger = logger.Named(s.name)
+	}
+
+	s.logger.Store(&scopeLogger{base: b, callers: callers, limits: limits, logger: logger})
+	return logger
+}
+
+// zapEnabler returns the zapcore.LevelEnabler of the level.
+func zapEnabler(lvl AtomicLevel) zapcore.LevelEnabler {
+	return zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+		threshold := lvl.Level()
+		return threshold != NoneLevel && l >= levelToZap[threshold]
+	})
+}
+
+// newLevelCore returns the core gated by the level enabler.
+func newLevelCore(core zapcore.Core, enab zapcore.LevelEnabler) zapcore.Core {
+	return &levelCore{Core: core, enab: enab}
+}
+
+// Enabled impls zapcore.Core.
+func (c *levelCore) Enabled(l zapcore.Level) bool {
+	return c.enab.Enabled(l) && c.Core.Enabled(l)
+}
+
+// With impls zapcore.Core.
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), enab: c.enab}
+}
+
+// Check impls zapcore.Core.
+func (c *levelCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.enab.Enabled(e.Level) {
+		return ce
+	}
+
+	return c.Core.Check(e, ce)
+}
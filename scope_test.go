@@ -0,0 +1,140 @@
+package lager
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// configureBuffer configures the log as JSON lines written to the returned buffer,
+// and restores the default options by the cleanup.
+func configureBuffer(t *testing.T, o *Options) *bytes.Buffer {
+	var buf bytes.Buffer
+	o.OutputPaths = nil
+	o.JSONEncoding = true
+	o.SpecificWriters = []io.Writer{&buf}
+	require.NoError(t, Configure(o))
+	t.Cleanup(func() { _ = Configure(DefaultOptions()) })
+	
+	return &buf
+}
+
+// decodeLines decodes the JSON lines of the buffer.
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	
+	return entries
+}
+
+func TestRegisterScope(t *testing.T) {
+	s := RegisterScope("test-register", "a test scope")
+	assert.Same(t, s, RegisterScope("test-register", "another description"))
+	assert.Same(t, s, FindScope("test-register"))
+	assert.Contains(t, Scopes(), "test-register")
+	assert.Nil(t, FindScope("test-unknown"))
+	assert.Equal(t, "test-register", s.Name())
+	assert.Equal(t, "a test scope", s.Description())
+	
+	for _, name := range []string{"", "a:b", "a,b"} {
+		assert.Panics(t, func() { RegisterScope(name, "") }, "expected to reject %q", name)
+	}
+}
+
+func TestScopeLevels(t *testing.T) {
+	s := RegisterScope("test-levels", "")
+	
+	o := DefaultOptions()
+	o.SetOutputLevel("test-levels", DebugLevel)
+	o.SetLogCallers("test-levels", true)
+	buf := configureBuffer(t, o)
+	
+	assert.Equal(t, DebugLevel, s.GetOutputLevel())
+	assert.Equal(t, InfoLevel, defaultScope.GetOutputLevel())
+	assert.True(t, s.GetLogCallers())
+	assert.False(t, defaultScope.GetLogCallers())
+	
+	_, file, line, _ := runtime.Caller(0)
+	s.Debug("debug", zap.Int("count", 1))
+	s.Infof("info %d", 2)
+	defaultScope.Debug("filtered")
+	zap.L().Debug("filtered")
+	
+	s.SetOutputLevel(WarnLevel)
+	s.Info("filtered")
+	s.Warn("warn")
+	
+	s.SetOutputLevel(NoneLevel)
+	s.Error("filtered")
+	
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "debug", entries[0]["level"])
+	assert.Equal(t, "test-levels", entries[0]["scope"])
+	assert.Equal(t, float64(1), entries[0]["count"])
+	assert.True(t, strings.HasSuffix(entries[0]["caller"].(string), filepath.Base(file)+":"+strconv.Itoa(line+1)),
+		"unexpected caller %v", entries[0]["caller"])
+	assert.Equal(t, "info 2", entries[1]["msg"])
+	assert.Equal(t, "warn", entries[2]["msg"])
+}
+
+func TestScopeNamed(t *testing.T) {
+	s := RegisterScope("test-named", "")
+	buf := configureBuffer(t, DefaultOptions())
+	
+	child := s.Named("client:a,b")
+	assert.Equal(t, "test-named.client_a_b", child.Name())
+	assert.Nil(t, FindScope(child.Name()), "expected the child unregistered")
+	
+	child.Debug("filtered")
+	s.SetOutputLevel(DebugLevel)
+	child.Debug("debug")
+	
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "test-named.client_a_b", entries[0]["scope"])
+}
+
+func TestScopeStackTrace(t *testing.T) {
+	s := RegisterScope("test-stack", "")
+	buf := configureBuffer(t, DefaultOptions())
+	
+	s.SetStackTraceLevel(ErrorLevel)
+	s.Warn("warn")
+	s.WithCallerSkip(0).Error("error")
+	
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 2)
+	assert.NotContains(t, entries[0], "stack")
+	assert.Contains(t, entries[1]["stack"], "TestScopeStackTrace")
+}
+
+func TestDefaultScopeGatesGlobalLogger(t *testing.T) {
+	buf := configureBuffer(t, DefaultOptions())
+	
+	zap.L().Debug("filtered")
+	defaultScope.SetOutputLevel(DebugLevel)
+	zap.L().Debug("debug")
+	
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "debug", entries[0]["msg"])
+	assert.NotContains(t, entries[0], "scope")
+}
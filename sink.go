@@ -0,0 +1,500 @@
+package lager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// the schemes of the built-in sinks.
+	fileSinkScheme     = "file"
+	udpSinkScheme      = "udp"
+	tcpSinkScheme      = "tcp"
+	unixSinkScheme     = "unix"
+	unixgramSinkScheme = "unixgram"
+	rotateSinkScheme   = "rotate"
+
+	defaultSinkDialTimeout = 5 * time.Second
+	defaultFileSinkMode    = 0o644
+)
+
+type (
+	// Sink a destination of the encoded log data, addressed by an URL within the OutputPaths.
+	Sink interface {
+		zapcore.WriteSyncer
+		io.Closer
+	}
+
+	// EntrySink a Sink consuming the whole entries instead of the encoded log data,
+	// so that it maps the level and the fields onto its own wire format, e.g. the syslog.
+	//
+	// within the OutputPaths, the entries are written by WriteEntry, whereas
+	// within the ErrOutputPaths, the encoded log data is written by Write.
+	EntrySink interface {
+		Sink
+		WriteEntry(ent zapcore.Entry, fields []zapcore.Field) error
+	}
+
+	// SinkFactory opens the Sink addressed by the URL.
+	SinkFactory func(u *url.URL) (Sink, error)
+
+	// entrySinkCore writes the entries to an EntrySink.
+	entrySinkCore struct {
+		zapcore.LevelEnabler
+		sink   EntrySink
+		fields []zapcore.Field
+	}
+
+	// stdSink wraps the standard I/O stream, which is never closed.
+	stdSink struct {
+		*os.File
+	}
+
+	// netSink writes the log data to a network connection,
+	// which is dialed on the first write and redialed once a write fails.
+	netSink struct {
+		network string
+		address string
+		timeout time.Duration
+
+		mu   sync.Mutex
+		conn net.Conn
+	}
+
+	// rotateSink writes the log data to a file rotated by size and age.
+	rotateSink struct {
+		*lumberjack.Logger
+	}
+)
+
+var (
+	sinkMu        sync.RWMutex
+	sinkFactories = map[string]SinkFactory{}
+
+	// the scheme syntax of the RFC 3986.
+	sinkSchemeRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*$`)
+)
+
+func init() {
+	for scheme, factory := range map[string]SinkFactory{
+		fileSinkScheme:     newFileSink,
+		udpSinkScheme:      newNetSink,
+		tcpSinkScheme:      newNetSink,
+		unixSinkScheme:     newNetSink,
+		unixgramSinkScheme: newNetSink,
+		rotateSinkScheme:   newRotateSinkFromURL,
+	} {
+		if err := RegisterSink(scheme, factory); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterSink registers the factory of the sinks addressed by the scheme within the OutputPaths and ErrOutputPaths,
+// e.g. a factory registered for "kafka" opens the sink of "kafka://broker:9092/topic".
+//
+// the built-in schemes are:
+//   - file:///var/log/app.log?mode=0600&append=false&mkdir=true
+//   - udp://localhost:514, tcp://localhost:514?timeout=3s
+//   - unix:///run/app.sock, unixgram:///run/app.sock
+//   - rotate:///var/log/app.log?maxSize=100&maxAge=30&maxBackups=10&compress=true&localTime=true
+//
+// a path without scheme is a file, except the special values stdout and stderr.
+func RegisterSink(scheme string, factory SinkFactory) error {
+	if !sinkSchemeRegexp.MatchString(scheme) {
+		return errors.Errorf("invalid sink scheme: %q", scheme)
+	}
+
+	if factory == nil {
+		return errors.Errorf("nil factory of the sink scheme: %q", scheme)
+	}
+
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+
+	if _, ok := sinkFactories[scheme]; ok {
+		return errors.Errorf("sink scheme already registered: %q", scheme)
+	}
+
+	sinkFactories[scheme] = factory
+	return nil
+}
+
+// unregisterSink removes the factory of the scheme, e.g. the one registered by a test.
+func unregisterSink(scheme string) {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+
+	delete(sinkFactories, scheme)
+}
+
+// openSinks opens the sinks addressed by the paths, and combines them into a single zapcore.WriteSyncer,
+// except the EntrySinks, which are returned apart. the returned CloseFunc closes all the sinks.
+func openSinks(paths ...string) (zapcore.WriteSyncer, []EntrySink, CloseFunc, error) {
+	sinks := make([]Sink, 0, len(paths))
+	closeFunc := func() error {
+		var err error
+		for _, s := range sinks {
+			err = errors.CombineErrors(err, s.Close())
+		}
+
+		return err
+	}
+
+	writers := make([]zapcore.WriteSyncer, 0, len(paths))
+	var entrySinks []EntrySink
+	for _, path := range paths {
+		s, err := newSink(path)
+		if err != nil {
+			_ = closeFunc()
+			return nil, nil, nil, errors.Wrapf(err, "failed to open the sink %q", path)
+		}
+
+		sinks = append(sinks, s)
+		if es, ok := s.(EntrySink); ok {
+			entrySinks = append(entrySinks, es)
+			continue
+		}
+
+		writers = append(writers, s)
+	}
+
+	return zapcore.NewMultiWriteSyncer(writers...), entrySinks, closeFunc, nil
+}
+
+// newEntrySinkCore returns the core writing the enabled entries to the sink.
+func newEntrySinkCore(enab zapcore.LevelEnabler, sink EntrySink) zapcore.Core {
+	return &entrySinkCore{LevelEnabler: enab, sink: sink}
+}
+
+// With impls zapcore.Core.
+func (c *entrySinkCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+
+	return &entrySinkCore{
+		LevelEnabler: c.LevelEnabler,
+		sink:         c.sink,
+		fields:       append(all, fields...),
+	}
+}
+
+// Check impls zapcore.Core.
+func (c *entrySinkCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+// Write impls zapcore.Core, the entries above the error level are synced immediately like the zapcore.ioCore.
+func (c *entrySinkCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	all := fields
+	if len(c.fields) > 0 {
+		all = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+		all = append(append(all, c.fields...), fields...)
+	}
+
+	if err := c.sink.WriteEntry(ent, all); err != nil {
+		return err
+	}
+
+	if ent.Level > zapcore.ErrorLevel {
+		_ = c.Sync()
+	}
+
+	return nil
+}
+
+// Sync impls zapcore.Core.
+func (c *entrySinkCore) Sync() error {
+	return c.sink.Sync()
+}
+
+// fieldsToMap returns the values of the fields keyed by their keys,
+// the nested objects and arrays are maps and slices of values.
+func fieldsToMap(fields []zapcore.Field) map[string]interface{} {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	return enc.Fields
+}
+
+// fieldValueString returns the text of a field value from fieldsToMap,
+// the nested objects and arrays are encoded as JSON.
+func fieldValueString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return v.String()
+	case map[string]interface{}, []interface{}:
+		if data, err := json.Marshal(v); err == nil {
+			return string(data)
+		}
+	}
+
+	return fmt.Sprint(v)
+}
+
+// newSink opens the sink addressed by the path.
+func newSink(path string) (Sink, error) {
+	switch path {
+	case "stdout":
+		return stdSink{os.Stdout}, nil
+	case "stderr":
+		return stdSink{os.Stderr}, nil
+	}
+
+	u, err := url.Parse(path)
+	// a path without scheme, or with a windows volume name, e.g. C:\app.log.
+	if err != nil || len(u.Scheme) <= 1 {
+		return openFile(path, os.O_APPEND, defaultFileSinkMode, false)
+	}
+
+	sinkMu.RLock()
+	factory, ok := sinkFactories[u.Scheme]
+	sinkMu.RUnlock()
+
+	if !ok {
+		return nil, errors.Errorf("no sink registered for the scheme: %q", u.Scheme)
+	}
+
+	return factory(u)
+}
+
+// newFileSink opens the sink of the file:// URL.
+func newFileSink(u *url.URL) (Sink, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, errors.Errorf("the file URL must not have a remote host: %q", u.Host)
+	}
+
+	if u.Path == "" {
+		return nil, errors.New("the file URL must have a path")
+	}
+
+	q := u.Query()
+	mode := uint64(defaultFileSinkMode)
+	if v := q.Get("mode"); v != "" {
+		var err error
+		if mode, err = strconv.ParseUint(v, 8, 32); err != nil {
+			return nil, errors.Wrapf(err, "invalid file mode: %q", v)
+		}
+	}
+
+	flag := os.O_APPEND
+	if v := q.Get("append"); v != "" {
+		appendFile, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid append option: %q", v)
+		}
+
+		if !appendFile {
+			flag = os.O_TRUNC
+		}
+	}
+
+	mkdir := false
+	if v := q.Get("mkdir"); v != "" {
+		var err error
+		if mkdir, err = strconv.ParseBool(v); err != nil {
+			return nil, errors.Wrapf(err, "invalid mkdir option: %q", v)
+		}
+	}
+
+	return openFile(u.Path, flag, os.FileMode(mode), mkdir)
+}
+
+// openFile opens the file for writing, the flag is either os.O_APPEND or os.O_TRUNC.
+func openFile(path string, flag int, mode os.FileMode, mkdir bool) (Sink, error) {
+	if mkdir {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, errors.Wrap(err, "failed to create the directory of the file")
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// newNetSink opens the sink of the udp://, tcp://, unix:// or unixgram:// URL.
+func newNetSink(u *url.URL) (Sink, error) {
+	address := u.Host
+	if u.Scheme == unixSinkScheme || u.Scheme == unixgramSinkScheme {
+		address = u.Path
+	}
+
+	if address == "" {
+		return nil, errors.Errorf("the %s URL must have an address", u.Scheme)
+	}
+
+	timeout, err := sinkDialTimeout(u)
+	if err != nil {
+		return nil, err
+	}
+
+	return &netSink{network: u.Scheme, address: address, timeout: timeout}, nil
+}
+
+// sinkDialTimeout returns the timeout option of the URL dialing a connection.
+func sinkDialTimeout(u *url.URL) (time.Duration, error) {
+	v := u.Query().Get("timeout")
+	if v == "" {
+		return defaultSinkDialTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(v)
+	return timeout, errors.Wrapf(err, "invalid timeout: %q", v)
+}
+
+// newRotateSinkFromURL opens the sink of the rotate:// URL.
+func newRotateSinkFromURL(u *url.URL) (Sink, error) {
+	if u.Path == "" {
+		return nil, errors.New("the rotate URL must have a path")
+	}
+
+	q := u.Query()
+	intOption := func(name string, fallback int) (int, error) {
+		v := q.Get(name)
+		if v == "" {
+			return fallback, nil
+		}
+
+		i, err := strconv.Atoi(v)
+		return i, errors.Wrapf(err, "invalid %s option: %q", name, v)
+	}
+	boolOption := func(name string) (bool, error) {
+		v := q.Get(name)
+		if v == "" {
+			return false, nil
+		}
+
+		b, err := strconv.ParseBool(v)
+		return b, errors.Wrapf(err, "invalid %s option: %q", name, v)
+	}
+
+	maxSize, err := intOption("maxSize", defaultRotationMaxSize)
+	if err != nil {
+		return nil, err
+	}
+	maxAge, err := intOption("maxAge", defaultRotationMaxAge)
+	if err != nil {
+		return nil, err
+	}
+	maxBackups, err := intOption("maxBackups", defaultRotationMaxBackups)
+	if err != nil {
+		return nil, err
+	}
+	compress, err := boolOption("compress")
+	if err != nil {
+		return nil, err
+	}
+	localTime, err := boolOption("localTime")
+	if err != nil {
+		return nil, err
+	}
+
+	s := newRotateSink(u.Path, maxSize, maxAge, maxBackups)
+	s.Compress = compress
+	s.LocalTime = localTime
+
+	return s, nil
+}
+
+// newRotateSink returns the sink writing to the file rotated by the size in megabytes and the age in days.
+func newRotateSink(path string, maxSize, maxAge, maxBackups int) *rotateSink {
+	return &rotateSink{Logger: &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    maxSize,
+		MaxAge:     maxAge,
+		MaxBackups: maxBackups,
+	}}
+}
+
+// Sync impls zapcore.WriteSyncer,
+// the standard I/O stream can't be synced when it's a terminal or a pipe, so the error is ignored.
+func (s stdSink) Sync() error {
+	_ = s.File.Sync()
+	return nil
+}
+
+// Close impls io.Closer, the standard I/O stream is never closed.
+func (s stdSink) Close() error {
+	return nil
+}
+
+// Write impls zapcore.WriteSyncer,
+// a failed write closes the connection, and the data is written once more to a newly dialed connection as a whole,
+// since the receiver of a stream drops the partial data of the closed connection, e.g. the octet-counted frames,
+// and a datagram is either sent or not.
+func (s *netSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if s.conn, err = net.DialTimeout(s.network, s.address, s.timeout); err != nil {
+				s.conn = nil
+				continue
+			}
+		}
+
+		if _, err = s.conn.Write(p); err == nil {
+			return len(p), nil
+		}
+
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+
+	return 0, errors.Wrapf(err, "failed to write to %s://%s", s.network, s.address)
+}
+
+// Sync impls zapcore.WriteSyncer, the data is written to the connection without buffering.
+func (s *netSink) Sync() error {
+	return nil
+}
+
+// Close impls io.Closer.
+func (s *netSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// Sync impls zapcore.WriteSyncer, the data is written to the file without buffering.
+func (s *rotateSink) Sync() error {
+	return nil
+}
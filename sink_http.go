@@ -0,0 +1,201 @@
+package lager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/dapings/lager/batch"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultHTTPPushTimeout = 10 * time.Second
+	httpPushCloseTimeout   = 30 * time.Second
+	// httpPushMaxErrorBody the maximum length of the response body reported by an error.
+	httpPushMaxErrorBody = 512
+)
+
+type (
+	// httpPushSink the EntrySink pushing the entries in batches to an HTTP endpoint, e.g. Loki or Elasticsearch.
+	// the entries are encoded as items when written, and the batches of items are exported by the batch.Batcher.
+	httpPushSink[T any] struct {
+		encode  func(ent zapcore.Entry, fields []zapcore.Field) (T, error)
+		batcher *batch.Batcher[T]
+	}
+
+	// httpPushClient posts the requests of the push sinks.
+	httpPushClient struct {
+		client  *http.Client
+		headers map[string]string
+		user    *url.Userinfo
+	}
+)
+
+// newHTTPPushClient returns the client, whose basic auth is the user info of the endpoint.
+func newHTTPPushClient(client *http.Client, headers map[string]string, endpoint *url.URL) *httpPushClient {
+	if client == nil {
+		client = &http.Client{Timeout: defaultHTTPPushTimeout}
+	}
+
+	return &httpPushClient{client: client, headers: headers, user: endpoint.User}
+}
+
+// post sends the body to the endpoint, and returns the response body.
+// the error of a rejected request is marked by batch.Permanent,
+// whereas the network errors and the 429 and 5xx statuses are retried by the batcher.
+func (c *httpPushClient) post(ctx context.Context, endpoint, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, batch.Permanent(err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	if c.user != nil {
+		password, _ := c.user.Password()
+		req.SetBasicAuth(c.user.Username(), password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to post to %s", req.URL.Redacted())
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the response of %s", req.URL.Redacted())
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return respBody, nil
+	}
+
+	if len(respBody) > httpPushMaxErrorBody {
+		respBody = respBody[:httpPushMaxErrorBody]
+	}
+
+	err = errors.Errorf("%s responded %s: %s", req.URL.Redacted(), resp.Status, respBody)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, err
+	}
+
+	return nil, batch.Permanent(err)
+}
+
+// WriteEntry impls EntrySink, the entry is dropped once the queue is full, which is reported by the batcher
+// rather than failing every write, see reportSinkDrops.
+func (s *httpPushSink[T]) WriteEntry(ent zapcore.Entry, fields []zapcore.Field) error {
+	item, err := s.encode(ent, fields)
+	if err != nil {
+		return err
+	}
+
+	s.batcher.Add(item)
+	return nil
+}
+
+// Write impls zapcore.WriteSyncer, the encoded log data is pushed as the message of an informational entry.
+func (s *httpPushSink[T]) Write(p []byte) (int, error) {
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now(), Message: string(bytes.TrimRight(p, "\n"))}
+	if err := s.WriteEntry(ent, nil); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Sync impls zapcore.WriteSyncer and pushes the pending entries.
+func (s *httpPushSink[T]) Sync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), httpPushCloseTimeout)
+	defer cancel()
+
+	return s.batcher.Flush(ctx)
+}
+
+// Close impls io.Closer, it pushes the pending entries and stops the batcher.
+func (s *httpPushSink[T]) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), httpPushCloseTimeout)
+	defer cancel()
+
+	return s.batcher.Close(ctx)
+}
+
+// reportSinkDrops returns the batch.Config OnDrop of the sink of the scheme, unless the config has one,
+// which writes the "dropped N messages" summary to the error output of the latest Configure,
+// rather than to the log, which may be written to the sink itself.
+func reportSinkDrops(cfg batch.Config, scheme string) batch.Config {
+	if cfg.OnDrop == nil {
+		cfg.OnDrop = func(n uint64) {
+			errSink := base.Load().errSink
+			_, _ = fmt.Fprintf(errSink, "%v the %s sink dropped %d messages\n", time.Now(), scheme, n)
+			_ = errSink.Sync()
+		}
+	}
+
+	return cfg
+}
+
+// httpPushEndpoint returns the http or https endpoint of the push sink URL, e.g. loki://host:3100?tls=true,
+// whose path defaults to the defaultPath.
+func httpPushEndpoint(u *url.URL, defaultPath string) (*url.URL, error) {
+	if u.Host == "" {
+		return nil, errors.Errorf("the %s URL must have a host", u.Scheme)
+	}
+
+	scheme := "http"
+	if v := u.Query().Get("tls"); v != "" {
+		tls, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid tls option: %q", v)
+		}
+		if tls {
+			scheme = "https"
+		}
+	}
+
+	path := u.Path
+	if path == "" {
+		path = defaultPath
+	}
+
+	return &url.URL{Scheme: scheme, User: u.User, Host: u.Host, Path: path}, nil
+}
+
+// batchConfigFromQuery returns the batch.Config of the options: batchSize, queueSize, flushInterval, maxRetries.
+func batchConfigFromQuery(q url.Values) (batch.Config, error) {
+	var cfg batch.Config
+	for name, target := range map[string]*int{
+		"batchSize":  &cfg.MaxBatchSize,
+		"queueSize":  &cfg.MaxQueueSize,
+		"maxRetries": &cfg.MaxRetries,
+	} {
+		if v := q.Get(name); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return cfg, errors.Wrapf(err, "invalid %s option: %q", name, v)
+			}
+			*target = i
+		}
+	}
+
+	if v := q.Get("flushInterval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, errors.Wrapf(err, "invalid flushInterval option: %q", v)
+		}
+		cfg.FlushInterval = d
+	}
+
+	return cfg, nil
+}
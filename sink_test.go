@@ -0,0 +1,250 @@
+package lager
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type memorySink struct {
+	data   []byte
+	synced bool
+	closed bool
+}
+
+func (m *memorySink) Write(p []byte) (int, error) {
+	m.data = append(m.data, p...)
+	return len(p), nil
+}
+
+func (m *memorySink) Sync() error {
+	m.synced = true
+	return nil
+}
+
+func (m *memorySink) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestRegisterSink(t *testing.T) {
+	mem := &memorySink{}
+	require.NoError(t, RegisterSink("memory", func(u *url.URL) (Sink, error) {
+		assert.Equal(t, "test", u.Host)
+		return mem, nil
+	}))
+	t.Cleanup(func() { unregisterSink("memory") })
+	
+	assert.Error(t, RegisterSink("memory", func(*url.URL) (Sink, error) { return mem, nil }), "duplicated scheme")
+	assert.Error(t, RegisterSink("1nvalid", func(*url.URL) (Sink, error) { return mem, nil }), "invalid scheme")
+	assert.Error(t, RegisterSink("nil", nil), "nil factory")
+	
+	o := DefaultOptions()
+	o.OutputPaths = []string{"memory://test"}
+	o.JSONEncoding = true
+	require.NoError(t, Configure(o))
+	
+	zap.L().Info("hello")
+	require.NoError(t, zap.L().Sync())
+	assert.Contains(t, string(mem.data), `"msg":"hello"`)
+	assert.True(t, mem.synced)
+	
+	require.NoError(t, Configure(DefaultOptions()))
+	assert.True(t, mem.closed)
+}
+
+func TestOpenSinksErrors(t *testing.T) {
+	for _, path := range []string{
+		"unknown://foo",
+		"file://remote/app.log",
+		"file:///tmp/app.log?mode=999",
+		"file:///tmp/app.log?append=foo",
+		"udp://",
+		"tcp://localhost:514?timeout=foo",
+		"rotate:///tmp/app.log?maxSize=foo",
+		"rotate:///tmp/app.log?compress=foo",
+		"rotate://",
+	} {
+		_, _, _, err := openSinks(path)
+		assert.Error(t, err, "expected to fail to open %q", path)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "app.log")
+	
+	_, _, _, err := openSinks("file://" + path)
+	assert.Error(t, err, "expected to fail without mkdir")
+	
+	for i := 0; i < 2; i++ {
+		ws, _, closeFunc, err := openSinks("file://" + path + "?mkdir=true&mode=0600")
+		require.NoError(t, err)
+		_, err = ws.Write([]byte("hello\n"))
+		require.NoError(t, err)
+		require.NoError(t, closeFunc())
+	}
+	
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\nhello\n", string(data))
+	
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	
+	ws, _, closeFunc, err := openSinks("file://" + path + "?append=false")
+	require.NoError(t, err)
+	_, err = ws.Write([]byte("truncated\n"))
+	require.NoError(t, err)
+	require.NoError(t, closeFunc())
+	
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "truncated\n", string(data))
+}
+
+func TestRotateSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	ws, _, closeFunc, err := openSinks("rotate://" + path + "?maxSize=1&maxBackups=2&compress=true&localTime=true")
+	require.NoError(t, err)
+	
+	_, err = ws.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	require.NoError(t, ws.Sync())
+	require.NoError(t, closeFunc())
+	
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(data))
+}
+
+func TestNetSinks(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer l.Close()
+		
+		received := make(chan string, 1)
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			received <- line
+		}()
+		
+		ws, _, closeFunc, err := openSinks("tcp://" + l.Addr().String())
+		require.NoError(t, err)
+		_, err = ws.Write([]byte("hello\n"))
+		require.NoError(t, err)
+		assert.Equal(t, "hello\n", <-received)
+		require.NoError(t, closeFunc())
+	})
+	
+	t.Run("udp", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer conn.Close()
+		
+		ws, _, closeFunc, err := openSinks("udp://" + conn.LocalAddr().String())
+		require.NoError(t, err)
+		_, err = ws.Write([]byte("hello"))
+		require.NoError(t, err)
+		
+		buf := make([]byte, 64)
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(buf[:n]))
+		require.NoError(t, closeFunc())
+	})
+	
+	t.Run("unixgram", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log.sock")
+		conn, err := net.ListenPacket("unixgram", path)
+		require.NoError(t, err)
+		defer conn.Close()
+		
+		ws, _, closeFunc, err := openSinks("unixgram://" + path)
+		require.NoError(t, err)
+		_, err = ws.Write([]byte("hello"))
+		require.NoError(t, err)
+		
+		buf := make([]byte, 64)
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(buf[:n]))
+		require.NoError(t, closeFunc())
+	})
+	
+	t.Run("reconnected mid-write", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer l.Close()
+		
+		received := make(chan []byte, 1)
+		go func() {
+			// the first connection is reset once a part of the data is read.
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = io.ReadFull(conn, make([]byte, 1024))
+			_ = conn.(*net.TCPConn).SetLinger(0)
+			_ = conn.Close()
+			
+			conn, err = l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			
+			data, _ := io.ReadAll(conn)
+			received <- data
+		}()
+		
+		data := bytes.Repeat([]byte("a line of the log\n"), 1<<20)
+		ws, _, closeFunc, err := openSinks("tcp://" + l.Addr().String())
+		require.NoError(t, err)
+		n, err := ws.Write(data)
+		require.NoError(t, err)
+		assert.Equal(t, len(data), n)
+		require.NoError(t, closeFunc())
+		assert.True(t, bytes.Equal(data, <-received), "expected the whole data written to the new connection")
+	})
+	
+	t.Run("unreachable", func(t *testing.T) {
+		ws, _, closeFunc, err := openSinks("unix://" + filepath.Join(t.TempDir(), "absent.sock"))
+		require.NoError(t, err, "the connection is dialed lazily")
+		_, err = ws.Write([]byte("hello"))
+		assert.Error(t, err)
+		require.NoError(t, closeFunc())
+	})
+}
+
+func TestConfigureRotateOutputPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rotate.log")
+	o := DefaultOptions()
+	o.OutputPaths = nil
+	o.RotateOutputPath = path
+	require.NoError(t, Configure(o))
+	
+	zap.L().Info("rotated")
+	require.NoError(t, Configure(DefaultOptions()))
+	
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "rotated")
+}
@@ -0,0 +1,269 @@
+//go:build go1.21
+
+package lager
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// slogScopeKey the key of the attr carrying the scope, like the name key of the encoders.
+	slogScopeKey = "scope"
+)
+
+type (
+	// slogHandler the slog.Handler writing the records to a lager scope.
+	slogHandler struct {
+		scope *Scope
+		// the fields of the WithAttrs, and the namespaces of the WithGroup.
+		fields []zap.Field
+	}
+
+	// slogGroup marshals the attrs of a slog group as a zap object.
+	slogGroup []slog.Attr
+
+	// slogCore the zapcore.Core writing the entries to a slog.Handler.
+	slogCore struct {
+		handler slog.Handler
+		attrs   []slog.Attr
+	}
+)
+
+var (
+	_ slog.Handler = (*slogHandler)(nil)
+
+	// levelToSlog maps the levels to the slog levels.
+	levelToSlog = map[zapcore.Level]slog.Level{
+		zapcore.DebugLevel:  slog.LevelDebug,
+		zapcore.InfoLevel:   slog.LevelInfo,
+		zapcore.WarnLevel:   slog.LevelWarn,
+		zapcore.ErrorLevel:  slog.LevelError,
+		zapcore.DPanicLevel: slog.LevelError + 4,
+		zapcore.PanicLevel:  slog.LevelError + 4,
+		zapcore.FatalLevel:  slog.LevelError + 4,
+	}
+)
+
+// NewSlogHandler returns a slog.Handler writing the records to the scope, where
+//   - the levels are mapped to the nearest lager level, up to the error level, e.g. slog.LevelWarn+1 is the warn level,
+//   - the Enabled follows the output level of the scope,
+//   - the WithAttrs are mapped to the zap fields, and the WithGroup to the zap namespaces, e.g.
+//
+//	logger := slog.New(lager.NewSlogHandler(lager.RegisterScope("ads", "the ads debugging")))
+//	logger.WithGroup("req").Info("pushed", "id", 1) // {"scope":"ads","msg":"pushed","req":{"id":1}}
+func NewSlogHandler(scope *Scope) slog.Handler {
+	return &slogHandler{scope: scope}
+}
+
+// slogToLevel returns the nearest lager level of the slog level,
+// the levels above the error level are the error level, since the fatal level exits.
+func slogToLevel(l slog.Level) Level {
+	switch {
+	case l < slog.LevelInfo-2:
+		return DebugLevel
+	case l < slog.LevelWarn-2:
+		return InfoLevel
+	case l < slog.LevelError-2:
+		return WarnLevel
+	default:
+		return ErrorLevel
+	}
+}
+
+// Enabled impls slog.Handler.
+func (h *slogHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return h.scope.Enabled(slogToLevel(l))
+}
+
+// Handle impls slog.Handler.
+// the fields carried by the context come first, so that they aren't nested in the groups.
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	ctxFields := contextFields(ctx)
+	fields := make([]zap.Field, 0, len(ctxFields)+len(h.fields)+r.NumAttrs())
+	fields = append(fields, ctxFields...)
+	fields = append(fields, h.fields...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendSlogAttr(fields, a)
+		return true
+	})
+
+	level := slogToLevel(r.Level)
+	h.scope.logRecord(level, r.Time, r.PC, r.Message, fields)
+	recordSpanEvent(ctx, h.scope.name, level, r.Message, fields[len(ctxFields):])
+	return nil
+}
+
+// WithAttrs impls slog.Handler.
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := append([]zap.Field(nil), h.fields...)
+	for _, a := range attrs {
+		fields = appendSlogAttr(fields, a)
+	}
+
+	return &slogHandler{scope: h.scope, fields: fields}
+}
+
+// WithGroup impls slog.Handler.
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &slogHandler{scope: h.scope, fields: append(append([]zap.Field(nil), h.fields...), zap.Namespace(name))}
+}
+
+// appendSlogAttr appends the zap field of the attr, the empty attrs are ignored,
+// and the attrs of a group without key are inlined.
+func appendSlogAttr(fields []zap.Field, a slog.Attr) []zap.Field {
+	v := a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	switch v.Kind() {
+	case slog.KindString:
+		return append(fields, zap.String(a.Key, v.String()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(a.Key, v.Int64()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(a.Key, v.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(a.Key, v.Float64()))
+	case slog.KindBool:
+		return append(fields, zap.Bool(a.Key, v.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(a.Key, v.Duration()))
+	case slog.KindTime:
+		return append(fields, zap.Time(a.Key, v.Time()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		if len(attrs) == 0 {
+			return fields
+		}
+
+		if a.Key == "" {
+			for _, ga := range attrs {
+				fields = appendSlogAttr(fields, ga)
+			}
+			return fields
+		}
+
+		return append(fields, zap.Object(a.Key, slogGroup(attrs)))
+	default:
+		if err, ok := v.Any().(error); ok {
+			return append(fields, zap.NamedError(a.Key, err))
+		}
+
+		return append(fields, zap.Any(a.Key, v.Any()))
+	}
+}
+
+// MarshalLogObject impls zapcore.ObjectMarshaler.
+func (g slogGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	var fields []zap.Field
+	for _, a := range g {
+		fields = appendSlogAttr(fields, a)
+	}
+
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	return nil
+}
+
+// NewSlogCore returns a zapcore.Core writing the entries to the slog.Handler,
+// the fields are the attrs of the records, and the scope is the "scope" attr.
+// it can be plugged into the configured core by an Extension, e.g.
+//
+//	lager.DefaultOptions().WithExtension(func(core zapcore.Core) (zapcore.Core, lager.CloseFunc, error) {
+//		return zapcore.NewTee(core, lager.NewSlogCore(handler)), nil, nil
+//	})
+func NewSlogCore(handler slog.Handler) zapcore.Core {
+	return &slogCore{handler: handler}
+}
+
+// Enabled impls zapcore.Core.
+func (c *slogCore) Enabled(l zapcore.Level) bool {
+	return c.handler.Enabled(context.Background(), zapToSlog(l))
+}
+
+// With impls zapcore.Core.
+func (c *slogCore) With(fields []zapcore.Field) zapcore.Core {
+	return &slogCore{handler: c.handler, attrs: append(append([]slog.Attr(nil), c.attrs...), fieldsToSlog(fields)...)}
+}
+
+// Check impls zapcore.Core.
+func (c *slogCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+
+	return ce
+}
+
+// Write impls zapcore.Core.
+func (c *slogCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	r := slog.NewRecord(e.Time, zapToSlog(e.Level), e.Message, e.Caller.PC)
+	if e.LoggerName != "" {
+		r.AddAttrs(slog.String(slogScopeKey, e.LoggerName))
+	}
+	r.AddAttrs(c.attrs...)
+	r.AddAttrs(fieldsToSlog(fields)...)
+
+	return c.handler.Handle(context.Background(), r)
+}
+
+// Sync impls zapcore.Core.
+func (c *slogCore) Sync() error {
+	return nil
+}
+
+// zapToSlog returns the slog level of the zap level.
+func zapToSlog(l zapcore.Level) slog.Level {
+	if sl, ok := levelToSlog[l]; ok {
+		return sl
+	}
+
+	return slog.Level(math.MaxInt32)
+}
+
+// fieldsToSlog returns the attrs of the fields.
+func fieldsToSlog(fields []zapcore.Field) []slog.Attr {
+	m := fieldsToMap(fields)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, slogAttr(k, m[k]))
+	}
+
+	return attrs
+}
+
+// slogAttr returns the attr of a field value from the zapcore.MapObjectEncoder.
+func slogAttr(key string, v interface{}) slog.Attr {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		attrs := make([]any, 0, len(v))
+		for k, nv := range v {
+			attrs = append(attrs, slogAttr(k, nv))
+		}
+		return slog.Group(key, attrs...)
+	case time.Duration:
+		return slog.Duration(key, v)
+	default:
+		return slog.Any(key, v)
+	}
+}
@@ -0,0 +1,111 @@
+//go:build go1.21
+
+package lager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+func TestSlogHandler(t *testing.T) {
+	s := RegisterScope("test-slog", "")
+	o := DefaultOptions()
+	o.SetLogCallers("test-slog", true)
+	buf := configureBuffer(t, o)
+	
+	logger := slog.New(NewSlogHandler(s)).With("tenant", "a")
+	_, file, line, _ := runtime.Caller(0)
+	logger.WithGroup("req").Info("pushed", "id", 1, slog.Group("peer", "addr", "10.0.0.1"))
+	logger.Debug("filtered")
+	logger.Log(context.Background(), slog.LevelWarn+1, "custom", "err", errors.New("boom"), "took", time.Second)
+	logger.Log(context.Background(), slog.LevelError+8, "capped")
+	
+	assert.False(t, logger.Enabled(context.Background(), slog.LevelDebug))
+	s.SetOutputLevel(DebugLevel)
+	assert.True(t, logger.Enabled(context.Background(), slog.LevelDebug-1))
+	
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "info", entries[0]["level"])
+	assert.Equal(t, "test-slog", entries[0]["scope"])
+	assert.Equal(t, "a", entries[0]["tenant"])
+	assert.Equal(t, map[string]interface{}{"id": float64(1), "peer": map[string]interface{}{"addr": "10.0.0.1"}}, entries[0]["req"])
+	assert.True(t, strings.HasSuffix(entries[0]["caller"].(string), filepath.Base(file)+":"+strconv.Itoa(line+1)),
+		"unexpected caller %v", entries[0]["caller"])
+	
+	assert.Equal(t, "warn", entries[1]["level"])
+	assert.Equal(t, "boom", entries[1]["err"])
+	assert.Equal(t, "1s", entries[1]["took"])
+	assert.Equal(t, "error", entries[2]["level"])
+}
+
+func TestSlogHandlerContext(t *testing.T) {
+	s := RegisterScope("test-slog-ctx", "")
+	buf := configureBuffer(t, DefaultOptions().WithSpanEvents(WarnLevel))
+	ctx, span, recorder := startSpan(t)
+	ctx = WithContext(ctx, zap.String("request_id", "r1"))
+	
+	slog.New(NewSlogHandler(s)).WithGroup("g").InfoContext(ctx, "slog", "k", "v")
+	slog.New(NewSlogHandler(s)).ErrorContext(ctx, "failed", "k", "v")
+	span.End()
+	
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "r1", entries[0]["request_id"])
+	assert.Equal(t, map[string]interface{}{"k": "v"}, entries[0]["g"])
+	assert.Equal(t, span.SpanContext().TraceID().String(), entries[0][TraceIDKey])
+	assert.Equal(t, span.SpanContext().SpanID().String(), entries[0][SpanIDKey])
+	
+	events := recorder.Ended()[0].Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, "failed", events[0].Name)
+	assert.Contains(t, events[0].Attributes, attribute.String("k", "v"))
+}
+
+func TestSlogToLevel(t *testing.T) {
+	for l, want := range map[slog.Level]Level{
+		slog.LevelDebug - 4: DebugLevel,
+		slog.LevelDebug:     DebugLevel,
+		slog.LevelInfo - 2:  InfoLevel,
+		slog.LevelInfo:      InfoLevel,
+		slog.LevelInfo + 1:  InfoLevel,
+		slog.LevelWarn:      WarnLevel,
+		slog.LevelError - 3: WarnLevel,
+		slog.LevelError:     ErrorLevel,
+		slog.LevelError + 4: ErrorLevel,
+	} {
+		assert.Equal(t, want, slogToLevel(l), "unexpected level of %s", l)
+	}
+}
+
+func TestSlogCore(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	logger := zap.New(NewSlogCore(handler)).Named("ads").With(zap.String("tenant", "a"))
+	
+	logger.Info("hello", zap.Int("count", 2), zap.Duration("took", time.Second))
+	logger.Debug("filtered")
+	
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, "INFO", got["level"])
+	assert.Equal(t, "hello", got["msg"])
+	assert.Equal(t, "ads", got["scope"])
+	assert.Equal(t, "a", got["tenant"])
+	assert.Equal(t, float64(2), got["count"])
+	assert.Equal(t, float64(time.Second), got["took"])
+}
@@ -0,0 +1,184 @@
+package lager
+
+import (
+	"strconv"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// the special field keys recognized by Google Cloud Logging,
+// see https://cloud.google.com/logging/docs/structured-logging#special-payload-fields.
+const (
+	StackdriverSeverityKey       = "severity"
+	StackdriverMessageKey        = "message"
+	StackdriverTimeKey           = "time"
+	StackdriverStackTraceKey     = "stack_trace"
+	StackdriverSourceLocationKey = "logging.googleapis.com/sourceLocation"
+	StackdriverLabelsKey         = "logging.googleapis.com/labels"
+	StackdriverOperationKey      = "logging.googleapis.com/operation"
+	StackdriverTraceKey          = "logging.googleapis.com/trace"
+	StackdriverSpanIDKey         = "logging.googleapis.com/spanId"
+	StackdriverTraceSampledKey   = "logging.googleapis.com/trace_sampled"
+
+	// stackdriverScopeLabel the label carrying the scope (logger name) of the entry.
+	stackdriverScopeLabel = "scope"
+)
+
+var levelToStackdriverSeverity = map[zapcore.Level]string{
+	zapcore.DebugLevel:  "DEBUG",
+	zapcore.InfoLevel:   "INFO",
+	zapcore.WarnLevel:   "WARNING",
+	zapcore.ErrorLevel:  "ERROR",
+	zapcore.DPanicLevel: "CRITICAL",
+	zapcore.PanicLevel:  "ALERT",
+	zapcore.FatalLevel:  "EMERGENCY",
+}
+
+type (
+	// stackdriverEncoder wraps a JSON encoder to add the special fields,
+	// which can't be expressed by a zapcore.EncoderConfig, e.g. the source location object.
+	stackdriverEncoder struct {
+		zapcore.Encoder
+		// the project of the traces, the trace correlation fields are left as-is if empty.
+		projectID string
+	}
+
+	// stackdriverSourceLocation the logging.googleapis.com/sourceLocation object.
+	stackdriverSourceLocation struct {
+		caller zapcore.EntryCaller
+	}
+
+	// stackdriverLabels the logging.googleapis.com/labels object.
+	stackdriverLabels map[string]string
+)
+
+// StackdriverEncoderConfig returns the encoder config producing the structured JSON schema
+// natively ingested by Google Cloud Logging, e.g. on GKE or Cloud Run.
+//
+// the caller and the logger name are left out, since they are written
+// as the sourceLocation and labels objects by the encoder of NewStackdriverEncoder.
+func StackdriverEncoderConfig() zapcore.EncoderConfig {
+	return zapcore.EncoderConfig{
+		TimeKey:        StackdriverTimeKey,
+		LevelKey:       StackdriverSeverityKey,
+		MessageKey:     StackdriverMessageKey,
+		StacktraceKey:  StackdriverStackTraceKey,
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    StackdriverLevelEncoder,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+	}
+}
+
+// NewStackdriverEncoder returns a JSON encoder, which writes the caller of the entry as
+// the logging.googleapis.com/sourceLocation object and the scope as a logging.googleapis.com/labels entry.
+func NewStackdriverEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return NewStackdriverProjectEncoder(cfg, "")
+}
+
+// NewStackdriverProjectEncoder returns an encoder like the NewStackdriverEncoder, which also writes
+// the trace correlation fields, see the TraceIDKey, as the logging.googleapis.com/trace of the project,
+// the logging.googleapis.com/spanId and the logging.googleapis.com/trace_sampled,
+// the fields are left as-is if the projectID is empty.
+func NewStackdriverProjectEncoder(cfg zapcore.EncoderConfig, projectID string) zapcore.Encoder {
+	return &stackdriverEncoder{Encoder: zapcore.NewJSONEncoder(cfg), projectID: projectID}
+}
+
+// StackdriverLevelEncoder encodes the level as the Google Cloud Logging severity.
+func StackdriverLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if severity, ok := levelToStackdriverSeverity[l]; ok {
+		enc.AppendString(severity)
+		return
+	}
+
+	enc.AppendString("DEFAULT")
+}
+
+// StackdriverTrace returns the field correlating the entry with the trace of the project.
+func StackdriverTrace(projectID, traceID string) zap.Field {
+	return zap.String(StackdriverTraceKey, "projects/"+projectID+"/traces/"+traceID)
+}
+
+// StackdriverSpanID returns the field correlating the entry with the span of the trace.
+func StackdriverSpanID(spanID string) zap.Field {
+	return zap.String(StackdriverSpanIDKey, spanID)
+}
+
+// StackdriverTraceSampled returns the field reporting whether the trace of the entry is sampled.
+func StackdriverTraceSampled(sampled bool) zap.Field {
+	return zap.Bool(StackdriverTraceSampledKey, sampled)
+}
+
+// Clone impls zapcore.Encoder.
+func (e *stackdriverEncoder) Clone() zapcore.Encoder {
+	return &stackdriverEncoder{Encoder: e.Encoder.Clone(), projectID: e.projectID}
+}
+
+// AddString impls zapcore.ObjectEncoder, which maps the trace correlation fields of the With.
+func (e *stackdriverEncoder) AddString(key, value string) {
+	e.stackdriverField(zap.String(key, value)).AddTo(e.Encoder)
+}
+
+// stackdriverField returns the special field of a trace correlation field, or the field itself.
+func (e *stackdriverEncoder) stackdriverField(f zapcore.Field) zapcore.Field {
+	if e.projectID == "" || f.Type != zapcore.StringType {
+		return f
+	}
+
+	switch f.Key {
+	case TraceIDKey:
+		return StackdriverTrace(e.projectID, f.String)
+	case SpanIDKey:
+		return StackdriverSpanID(f.String)
+	case TraceFlagsKey:
+		flags, err := strconv.ParseUint(f.String, 16, 8)
+		if err != nil {
+			return f
+		}
+		return StackdriverTraceSampled(trace.TraceFlags(flags).IsSampled())
+	default:
+		return f
+	}
+}
+
+// EncodeEntry impls zapcore.Encoder.
+func (e *stackdriverEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	all := make([]zapcore.Field, 0, len(fields)+2)
+	for _, f := range fields {
+		all = append(all, e.stackdriverField(f))
+	}
+
+	if ent.Caller.Defined {
+		all = append(all, zap.Object(StackdriverSourceLocationKey, stackdriverSourceLocation{caller: ent.Caller}))
+	}
+
+	if ent.LoggerName != "" {
+		all = append(all, zap.Object(StackdriverLabelsKey, stackdriverLabels{stackdriverScopeLabel: ent.LoggerName}))
+	}
+
+	return e.Encoder.EncodeEntry(ent, all)
+}
+
+// MarshalLogObject impls zapcore.ObjectMarshaler.
+func (l stackdriverSourceLocation) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("file", l.caller.File)
+	// the line is an int64 within the LogEntrySourceLocation, which is a string in the proto3 JSON.
+	enc.AddString("line", strconv.Itoa(l.caller.Line))
+	if l.caller.Function != "" {
+		enc.AddString("function", l.caller.Function)
+	}
+
+	return nil
+}
+
+// MarshalLogObject impls zapcore.ObjectMarshaler.
+func (l stackdriverLabels) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for k, v := range l {
+		enc.AddString(k, v)
+	}
+
+	return nil
+}
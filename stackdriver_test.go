@@ -0,0 +1,112 @@
+package lager
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestStackdriverEncoder(t *testing.T) {
+	enc := NewStackdriverEncoder(StackdriverEncoderConfig())
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Date(2022, 9, 1, 8, 0, 0, 0, time.UTC),
+		LoggerName: "ads",
+		Message:    "hello",
+		Caller:     zapcore.NewEntryCaller(0, "lager/stackdriver_test.go", 42, true),
+	}
+	
+	buf, err := enc.EncodeEntry(ent, []zapcore.Field{
+		zap.String("key", "value"),
+		StackdriverTrace("my-project", "abc"),
+		StackdriverSpanID("def"),
+		StackdriverTraceSampled(true),
+	})
+	require.NoError(t, err)
+	
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, map[string]interface{}{
+		StackdriverSeverityKey: "WARNING",
+		StackdriverMessageKey:  "hello",
+		StackdriverTimeKey:     "2022-09-01T08:00:00Z",
+		StackdriverSourceLocationKey: map[string]interface{}{
+			"file": "lager/stackdriver_test.go",
+			"line": "42",
+		},
+		StackdriverLabelsKey:       map[string]interface{}{"scope": "ads"},
+		StackdriverTraceKey:        "projects/my-project/traces/abc",
+		StackdriverSpanIDKey:       "def",
+		StackdriverTraceSampledKey: true,
+		"key":                      "value",
+	}, got)
+}
+
+func TestStackdriverEncoderWithoutCallerAndScope(t *testing.T) {
+	enc := NewStackdriverEncoder(StackdriverEncoderConfig())
+	enc.AddString("with", "field")
+	
+	buf, err := enc.Clone().EncodeEntry(zapcore.Entry{Level: zapcore.FatalLevel, Message: "bye"}, nil)
+	require.NoError(t, err)
+	
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, "EMERGENCY", got[StackdriverSeverityKey])
+	assert.Equal(t, "field", got["with"])
+	assert.NotContains(t, got, StackdriverSourceLocationKey)
+	assert.NotContains(t, got, StackdriverLabelsKey)
+}
+
+func TestStackdriverProjectEncoder(t *testing.T) {
+	enc := NewStackdriverProjectEncoder(StackdriverEncoderConfig(), "my-project")
+	enc.AddString(TraceIDKey, "4bf92f3577b34da6a3ce929d0e0e4736")
+	
+	buf, err := enc.Clone().EncodeEntry(zapcore.Entry{Level: zapcore.InfoLevel, Message: "traced"}, []zapcore.Field{
+		zap.String(SpanIDKey, "00f067aa0ba902b7"),
+		zap.String(TraceFlagsKey, "01"),
+	})
+	require.NoError(t, err)
+	
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, "projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736", got[StackdriverTraceKey])
+	assert.Equal(t, "00f067aa0ba902b7", got[StackdriverSpanIDKey])
+	assert.Equal(t, true, got[StackdriverTraceSampledKey])
+	assert.NotContains(t, got, TraceIDKey)
+	assert.NotContains(t, got, SpanIDKey)
+	assert.NotContains(t, got, TraceFlagsKey)
+	
+	// left as-is without the project.
+	buf, err = NewStackdriverEncoder(StackdriverEncoderConfig()).EncodeEntry(zapcore.Entry{Message: "traced"}, []zapcore.Field{
+		zap.String(SpanIDKey, "00f067aa0ba902b7"),
+	})
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `"span_id":"00f067aa0ba902b7"`)
+}
+
+func TestStackdriverLevelEncoder(t *testing.T) {
+	testCases := map[zapcore.Level]string{
+		zapcore.DebugLevel:  "DEBUG",
+		zapcore.InfoLevel:   "INFO",
+		zapcore.WarnLevel:   "WARNING",
+		zapcore.ErrorLevel:  "ERROR",
+		zapcore.DPanicLevel: "CRITICAL",
+		zapcore.PanicLevel:  "ALERT",
+		zapcore.FatalLevel:  "EMERGENCY",
+		zapcore.Level(42):   "DEFAULT",
+	}
+	
+	for l, severity := range testCases {
+		enc := zapcore.NewMapObjectEncoder()
+		assert.NoError(t, enc.AddArray("severity", zapcore.ArrayMarshalerFunc(func(ae zapcore.ArrayEncoder) error {
+			StackdriverLevelEncoder(l, ae)
+			return nil
+		})))
+		assert.Equal(t, []interface{}{severity}, enc.Fields["severity"], "unexpected severity of %s", l)
+	}
+}
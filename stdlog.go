@@ -0,0 +1,115 @@
+package lager
+
+import (
+	"bytes"
+	"log"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/atomic"
+)
+
+const (
+	// the layouts of the date and time written by the standard log package.
+	stdLogDateLayout         = "2006/01/02 "
+	stdLogTimeLayout         = "15:04:05 "
+	stdLogMicrosecondsLayout = "15:04:05.000000 "
+
+	// stdLogCallerSkip the callers skipped by the caller annotation:
+	// the Write, the log.(*Logger).output and the log function, e.g. log.Printf.
+	stdLogCallerSkip = 3
+)
+
+var (
+	// the flags and the prefix of the standard logger parsed by the stdLogWriter, which can't read them from the
+	// standard logger, since it holds its mutex while writing before go1.21, see the SetStdLogFlags and SetStdLogPrefix.
+	stdLogFlags  = atomic.NewInt32(0)
+	stdLogPrefix = atomic.NewString("")
+)
+
+type (
+	// stdLogWriter writes the output of the standard log package to a scope.
+	stdLogWriter struct {
+		scope *Scope
+		level Level
+	}
+)
+
+// RedirectStdLog redirects the output of the standard log package into the scope at the level,
+// which is the debug, info, warn or error level, and returns a function restoring the standard logger, e.g. for tests.
+//
+// the header written by the standard logger is parsed by its prefix and flags and dropped,
+// so that the time and the caller aren't duplicated. the flags are cleared by the redirection, and the ones set
+// after it by the SetStdLogFlags and SetStdLogPrefix are parsed, but not the ones set by the log.SetFlags
+// and log.SetPrefix, which are written as a part of the message.
+func RedirectStdLog(scope *Scope, level Level) (func(), error) {
+	if level < ErrorLevel || level > DebugLevel {
+		return nil, errors.Errorf("unsupported level of the standard log: %s", level)
+	}
+
+	flags, prefix, output := log.Flags(), log.Prefix(), log.Writer()
+	SetStdLogFlags(0)
+	SetStdLogPrefix(prefix)
+	log.SetOutput(&stdLogWriter{scope: scope.WithCallerSkip(stdLogCallerSkip), level: level})
+
+	return func() {
+		SetStdLogFlags(flags)
+		SetStdLogPrefix(prefix)
+		log.SetOutput(output)
+	}, nil
+}
+
+// SetStdLogFlags sets the flags of the standard logger, which are parsed from the standard log redirected by
+// the RedirectStdLog.
+func SetStdLogFlags(flags int) {
+	stdLogFlags.Store(int32(flags))
+	log.SetFlags(flags)
+}
+
+// SetStdLogPrefix sets the prefix of the standard logger, which is parsed from the standard log redirected by
+// the RedirectStdLog.
+func SetStdLogPrefix(prefix string) {
+	stdLogPrefix.Store(prefix)
+	log.SetPrefix(prefix)
+}
+
+// Write impls io.Writer.
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	msg := parseStdLog(string(bytes.TrimSuffix(p, []byte("\n"))), int(stdLogFlags.Load()), stdLogPrefix.Load())
+	w.scope.Log(w.level, msg)
+
+	return len(p), nil
+}
+
+// parseStdLog returns the message of a line written by the standard logger of the flags and the prefix,
+// which is: the prefix unless the log.Lmsgprefix, the date, the time, the file:line, the prefix of the log.Lmsgprefix,
+// and the message.
+func parseStdLog(line string, flags int, prefix string) string {
+	if flags&log.Lmsgprefix == 0 {
+		line = strings.TrimPrefix(line, prefix)
+	}
+
+	if flags&log.Ldate != 0 && len(line) >= len(stdLogDateLayout) {
+		line = line[len(stdLogDateLayout):]
+	}
+
+	switch {
+	case flags&log.Lmicroseconds != 0 && len(line) >= len(stdLogMicrosecondsLayout):
+		line = line[len(stdLogMicrosecondsLayout):]
+	case flags&log.Ltime != 0 && len(line) >= len(stdLogTimeLayout):
+		line = line[len(stdLogTimeLayout):]
+	}
+
+	if flags&(log.Lshortfile|log.Llongfile) != 0 {
+		// the file:line ends with a colon and a space.
+		if i := strings.Index(line, ": "); i >= 0 {
+			line = line[i+2:]
+		}
+	}
+
+	if flags&log.Lmsgprefix != 0 {
+		line = strings.TrimPrefix(line, prefix)
+	}
+
+	return line
+}
@@ -0,0 +1,88 @@
+package lager
+
+import (
+	"log"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectStdLog(t *testing.T) {
+	s := RegisterScope("test-stdlog", "")
+	o := DefaultOptions()
+	o.SetLogCallers("test-stdlog", true)
+	buf := configureBuffer(t, o)
+	
+	log.SetPrefix("[lib] ")
+	log.SetFlags(log.LstdFlags)
+	restore, err := RedirectStdLog(s, WarnLevel)
+	require.NoError(t, err)
+	
+	_, file, line, _ := runtime.Caller(0)
+	log.Printf("hello %d", 1)
+	// the flags set after the redirection.
+	SetStdLogFlags(log.LstdFlags | log.Lmicroseconds | log.Lshortfile | log.Lmsgprefix)
+	log.Println("world")
+	
+	restore()
+	assert.Equal(t, log.LstdFlags, log.Flags())
+	assert.Equal(t, "[lib] ", log.Prefix())
+	log.SetPrefix("")
+	
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "warn", entries[0]["level"])
+	assert.Equal(t, "test-stdlog", entries[0]["scope"])
+	assert.Equal(t, "hello 1", entries[0]["msg"])
+	assert.True(t, strings.HasSuffix(entries[0]["caller"].(string), filepath.Base(file)+":"+strconv.Itoa(line+1)),
+		"unexpected caller %v", entries[0]["caller"])
+	assert.Equal(t, "world", entries[1]["msg"])
+	
+	_, err = RedirectStdLog(s, FatalLevel)
+	assert.Error(t, err)
+	_, err = RedirectStdLog(s, NoneLevel)
+	assert.Error(t, err)
+}
+
+func TestRedirectStdLogNoDeadlock(t *testing.T) {
+	buf := configureBuffer(t, DefaultOptions())
+	restore, err := RedirectStdLog(defaultScope, InfoLevel)
+	require.NoError(t, err)
+	defer restore()
+	
+	// the standard logger holds its mutex while writing before go1.21, which the writer mustn't take.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		log.Print("not deadlocked")
+	}()
+	
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the standard log deadlocked")
+	}
+	assert.Equal(t, "not deadlocked", decodeLines(t, buf)[0]["msg"])
+}
+
+func TestParseStdLog(t *testing.T) {
+	for _, tc := range []struct {
+		line   string
+		flags  int
+		prefix string
+		want   string
+	}{
+		{"hello", 0, "", "hello"},
+		{"p: 2009/01/23 01:23:23 hello", log.LstdFlags, "p: ", "hello"},
+		{"01:23:23.123123 file.go:23: hello: world", log.Lmicroseconds | log.Lshortfile, "", "hello: world"},
+		{"2009/01/23 /a/b/file.go:23: p: hello", log.Ldate | log.Llongfile | log.Lmsgprefix, "p: ", "hello"},
+	} {
+		assert.Equal(t, tc.want, parseStdLog(tc.line, tc.flags, tc.prefix), "unexpected message of %q", tc.line)
+	}
+}
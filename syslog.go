@@ -0,0 +1,365 @@
+package lager
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	syslogSinkScheme = "syslog"
+
+	// the formats of the syslog messages.
+	syslogFormatRFC5424 = "rfc5424"
+	syslogFormatRFC3164 = "rfc3164"
+
+	// defaultSyslogSDID the SD-ID of the structured data carrying the fields,
+	// which is under the private enterprise number reserved for documentation.
+	defaultSyslogSDID    = "fields@32473"
+	defaultSyslogNetwork = udpSinkScheme
+	defaultSyslogPort    = "514"
+	defaultSyslogSocket  = "/dev/log"
+	syslogNilValue       = "-"
+
+	// the syslog severities of the RFC 5424.
+	syslogEmergency = 0
+	syslogAlert     = 1
+	syslogCritical  = 2
+	syslogError     = 3
+	syslogWarning   = 4
+	syslogInfo      = 6
+	syslogDebug     = 7
+)
+
+var (
+	// levelToSyslogSeverity maps the levels to the syslog severities:
+	// the DebugLevel, InfoLevel, WarnLevel and ErrorLevel map to their namesakes,
+	// the FatalLevel maps to emergency since the process exits, the zap panic levels to critical and alert.
+	levelToSyslogSeverity = map[zapcore.Level]int{
+		zapcore.DebugLevel:  syslogDebug,
+		zapcore.InfoLevel:   syslogInfo,
+		zapcore.WarnLevel:   syslogWarning,
+		zapcore.ErrorLevel:  syslogError,
+		zapcore.DPanicLevel: syslogCritical,
+		zapcore.PanicLevel:  syslogAlert,
+		zapcore.FatalLevel:  syslogEmergency,
+	}
+
+	syslogFacilities = map[string]int{
+		"kern":     0,
+		"user":     1,
+		"mail":     2,
+		"daemon":   3,
+		"auth":     4,
+		"syslog":   5,
+		"lpr":      6,
+		"news":     7,
+		"uucp":     8,
+		"cron":     9,
+		"authpriv": 10,
+		"ftp":      11,
+		"local0":   16,
+		"local1":   17,
+		"local2":   18,
+		"local3":   19,
+		"local4":   20,
+		"local5":   21,
+		"local6":   22,
+		"local7":   23,
+	}
+
+	// syslogSDValueEscaper escapes the PARAM-VALUE of the structured data.
+	syslogSDValueEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+)
+
+type (
+	// syslogSink writes the entries as syslog messages, see newSyslogSink.
+	syslogSink struct {
+		transport *netSink
+		// whether the transport is a stream, whose messages are framed by the octet counting of the RFC 6587.
+		stream   bool
+		format   string
+		facility int
+		sdID     string
+		hostname string
+		appName  string
+		pid      int
+	}
+)
+
+func init() {
+	if err := RegisterSink(syslogSinkScheme, newSyslogSink); err != nil {
+		panic(err)
+	}
+}
+
+// newSyslogSink opens the sink of the syslog:// URL, e.g.
+//   - syslog://localhost:514?facility=local0, over UDP by default
+//   - syslog://localhost:601?network=tcp&format=rfc3164
+//   - syslog:///dev/log?network=unixgram
+//
+// the lager levels are mapped to the syslog severities, the app id (or the appName option) is the APP-NAME,
+// the scope is the MSGID, and the fields are the structured data of the RFC 5424 (or appended to the MSG of the RFC 3164).
+func newSyslogSink(u *url.URL) (Sink, error) {
+	q := u.Query()
+
+	network := q.Get("network")
+	if network == "" {
+		network = defaultSyslogNetwork
+		if u.Host == "" {
+			network = unixgramSinkScheme
+		}
+	}
+
+	address := u.Host
+	switch network {
+	case udpSinkScheme, tcpSinkScheme:
+		if address == "" {
+			return nil, errors.New("the syslog URL must have a host")
+		}
+		if u.Port() == "" {
+			address += ":" + defaultSyslogPort
+		}
+	case unixSinkScheme, unixgramSinkScheme:
+		address = u.Path
+		if address == "" {
+			address = defaultSyslogSocket
+		}
+	default:
+		return nil, errors.Errorf("unsupported syslog network: %q", network)
+	}
+
+	format := q.Get("format")
+	switch format {
+	case "":
+		format = syslogFormatRFC5424
+	case syslogFormatRFC5424, syslogFormatRFC3164:
+	default:
+		return nil, errors.Errorf("unsupported syslog format: %q", format)
+	}
+
+	facility := syslogFacilities["user"]
+	if v := q.Get("facility"); v != "" {
+		var ok bool
+		if facility, ok = syslogFacilities[v]; !ok {
+			return nil, errors.Errorf("unsupported syslog facility: %q", v)
+		}
+	}
+
+	sdID := q.Get("sdID")
+	if sdID == "" {
+		sdID = defaultSyslogSDID
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = syslogNilValue
+	}
+
+	timeout, err := sinkDialTimeout(u)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogSink{
+		transport: &netSink{network: network, address: address, timeout: timeout},
+		stream:    network == tcpSinkScheme || network == unixSinkScheme,
+		format:    format,
+		facility:  facility,
+		sdID:      sdID,
+		hostname:  hostname,
+		appName:   q.Get("appName"),
+		pid:       os.Getpid(),
+	}, nil
+}
+
+// WriteEntry impls EntrySink.
+func (s *syslogSink) WriteEntry(ent zapcore.Entry, fields []zapcore.Field) error {
+	return s.send(ent, fieldsToMap(fields))
+}
+
+// Write impls zapcore.WriteSyncer, the encoded log data is sent as the message,
+// whose severity is the one of its level field if it's a JSON object, e.g. encoded by the JSON encoder, or else info.
+func (s *syslogSink) Write(p []byte) (int, error) {
+	msg := bytes.TrimRight(p, "\n")
+	ent := zapcore.Entry{Level: encodedLevel(msg), Time: time.Now(), Message: string(msg)}
+	if err := s.send(ent, nil); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Sync impls zapcore.WriteSyncer.
+func (s *syslogSink) Sync() error {
+	return s.transport.Sync()
+}
+
+// Close impls io.Closer.
+func (s *syslogSink) Close() error {
+	return s.transport.Close()
+}
+
+// encodedLevel returns the level of the level field of the JSON-encoded entry, or the info level if none.
+func encodedLevel(data []byte) zapcore.Level {
+	var encoded struct {
+		Level string `json:"level"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return zapcore.InfoLevel
+	}
+
+	l, err := zapcore.ParseLevel(encoded.Level)
+	if err != nil {
+		return zapcore.InfoLevel
+	}
+
+	return l
+}
+
+// send formats and writes the message.
+func (s *syslogSink) send(ent zapcore.Entry, fields map[string]interface{}) error {
+	var msg []byte
+	switch s.format {
+	case syslogFormatRFC3164:
+		msg = s.formatRFC3164(ent, fields)
+	default:
+		msg = s.formatRFC5424(ent, fields)
+	}
+
+	if s.stream {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	_, err := s.transport.Write(msg)
+	return err
+}
+
+// formatRFC5424 formats the message as:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID PARAM-NAME="PARAM-VALUE" ...] MSG.
+func (s *syslogSink) formatRFC5424(ent zapcore.Entry, fields map[string]interface{}) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("<" + strconv.Itoa(s.priority(ent.Level)) + ">1 ")
+	buf.WriteString(ent.Time.Format("2006-01-02T15:04:05.000000Z07:00") + " ")
+	buf.WriteString(syslogHeaderValue(s.hostname, 255) + " ")
+	buf.WriteString(syslogHeaderValue(s.appNameOf(fields), 48) + " ")
+	buf.WriteString(strconv.Itoa(s.pid) + " ")
+	buf.WriteString(syslogHeaderValue(ent.LoggerName, 32) + " ")
+
+	keys := syslogFieldKeys(fields)
+	if len(keys) == 0 {
+		buf.WriteString(syslogNilValue)
+	} else {
+		buf.WriteString("[" + s.sdID)
+		for _, k := range keys {
+			buf.WriteString(" " + syslogSDName(k) + `="` + syslogSDValueEscaper.Replace(fieldValueString(fields[k])) + `"`)
+		}
+		buf.WriteString("]")
+	}
+
+	if ent.Message != "" {
+		buf.WriteString(" " + ent.Message)
+	}
+
+	return buf.Bytes()
+}
+
+// formatRFC3164 formats the message as:
+// <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG key=value ...
+func (s *syslogSink) formatRFC3164(ent zapcore.Entry, fields map[string]interface{}) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("<" + strconv.Itoa(s.priority(ent.Level)) + ">")
+	buf.WriteString(ent.Time.Format(time.Stamp) + " ")
+	buf.WriteString(s.hostname + " ")
+	buf.WriteString(syslogHeaderValue(s.appNameOf(fields), 32) + "[" + strconv.Itoa(s.pid) + "]: ")
+	if ent.LoggerName != "" {
+		buf.WriteString(ent.LoggerName + " ")
+	}
+	buf.WriteString(ent.Message)
+
+	for _, k := range syslogFieldKeys(fields) {
+		buf.WriteString(" " + k + "=" + strconv.Quote(fieldValueString(fields[k])))
+	}
+
+	return buf.Bytes()
+}
+
+// priority returns the PRI of the level.
+func (s *syslogSink) priority(l zapcore.Level) int {
+	severity, ok := levelToSyslogSeverity[l]
+	if !ok {
+		severity = syslogInfo
+	}
+
+	return s.facility*8 + severity
+}
+
+// appNameOf returns the APP-NAME, which is the appName option, the app id field, or the executable name.
+func (s *syslogSink) appNameOf(fields map[string]interface{}) string {
+	if s.appName != "" {
+		return s.appName
+	}
+
+	if appID, ok := fields[logPlaceholderAppID].(string); ok && appID != "" {
+		return appID
+	}
+
+	return filepath.Base(os.Args[0])
+}
+
+// syslogFieldKeys returns the sorted keys of the fields, except the app id.
+func syslogFieldKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if k != logPlaceholderAppID {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// syslogHeaderValue returns the header value made of at most maxLen printable US-ASCII characters, or the NILVALUE.
+func syslogHeaderValue(v string, maxLen int) string {
+	v = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, v)
+
+	if v == "" {
+		return syslogNilValue
+	}
+	if len(v) > maxLen {
+		v = v[:maxLen]
+	}
+
+	return v
+}
+
+// syslogSDName returns the SD-NAME made of at most 32 printable US-ASCII characters except '=', ' ', ']' and '"'.
+func syslogSDName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, name)
+
+	if len(name) > 32 {
+		name = name[:32]
+	}
+
+	return name
+}
@@ -0,0 +1,143 @@
+package lager
+
+import (
+	"bufio"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSyslogSinkRFC5424(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	
+	o := DefaultOptions().WithAppID("my-app")
+	o.OutputPaths = []string{"syslog://" + conn.LocalAddr().String() + "?facility=local0"}
+	require.NoError(t, Configure(o))
+	defer func() { require.NoError(t, Configure(DefaultOptions())) }()
+	
+	zap.L().Named("ads").Warn("hello world", zap.String("user", `a"b]`), zap.Int("count", 3))
+	
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	
+	// local0 * 8 + warning.
+	pattern := `^<132>1 \S+ \S+ my-app ` + strconv.Itoa(os.Getpid()) +
+		` ads \[fields@32473 count="3" user="a\\"b\\]"\] hello world$`
+	assert.Regexp(t, regexp.MustCompile(pattern), string(buf[:n]))
+}
+
+func TestSyslogSinkRFC3164OverTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		
+		r := bufio.NewReader(conn)
+		length, _ := r.ReadString(' ')
+		size, _ := strconv.Atoi(strings.TrimSpace(length))
+		msg := make([]byte, size)
+		_, _ = r.Read(msg)
+		received <- string(msg)
+	}()
+	
+	u, err := url.Parse("syslog://" + l.Addr().String() + "?network=tcp&format=rfc3164&appName=tagged&facility=daemon")
+	require.NoError(t, err)
+	s, err := newSyslogSink(u)
+	require.NoError(t, err)
+	defer s.Close()
+	
+	core := newEntrySinkCore(zapcore.DebugLevel, s.(EntrySink))
+	zap.New(core).Error("failed", zap.Bool("retry", true))
+	
+	// daemon * 8 + error.
+	assert.Regexp(t, regexp.MustCompile(`^<27>\w{3} [ \d]\d \d\d:\d\d:\d\d \S+ tagged\[\d+\]: failed retry="true"$`), <-received)
+}
+
+func TestSyslogSinkWrite(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	
+	ws, entrySinks, closeFunc, err := openSinks("syslog://" + conn.LocalAddr().String() + "?appName=app")
+	require.NoError(t, err)
+	defer closeFunc()
+	require.Len(t, entrySinks, 1)
+	
+	_, err = entrySinks[0].Write([]byte("raw\n"))
+	require.NoError(t, err)
+	_, err = entrySinks[0].Write([]byte(`{"level":"error","msg":"encoded"}` + "\n"))
+	require.NoError(t, err)
+	_, err = ws.Write([]byte("not an entry sink"))
+	require.NoError(t, err)
+	
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^<14>1 \S+ \S+ app \d+ - - raw$`), string(buf[:n]))
+	
+	// user * 8 + error.
+	n, _, err = conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^<11>1 \S+ \S+ app \d+ - - \{"level":"error","msg":"encoded"\}$`), string(buf[:n]))
+}
+
+func TestSyslogSinkErrorRouting(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	
+	o := DefaultOptions().WithErrorRouting(true)
+	o.ErrOutputPaths = []string{"syslog://" + conn.LocalAddr().String() + "?appName=app"}
+	configureBuffer(t, o)
+	
+	zap.L().Error("failed", zap.Int("attempt", 2))
+	
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	// user * 8 + error, the entry is sent with its fields rather than encoded.
+	assert.Regexp(t, regexp.MustCompile(`^<11>1 \S+ \S+ app \d+ - \[fields@32473 attempt="2"\] failed$`), string(buf[:n]))
+}
+
+func TestSyslogSinkErrors(t *testing.T) {
+	for _, path := range []string{
+		"syslog://?network=udp",
+		"syslog://localhost?network=foo",
+		"syslog://localhost?format=foo",
+		"syslog://localhost?facility=foo",
+		"syslog://localhost?timeout=foo",
+	} {
+		_, _, _, err := openSinks(path)
+		assert.Error(t, err, "expected to fail to open %q", path)
+	}
+}
+
+func TestSyslogHeaderValues(t *testing.T) {
+	assert.Equal(t, "-", syslogHeaderValue("", 10))
+	assert.Equal(t, "a_b", syslogHeaderValue("a b", 10))
+	assert.Equal(t, "abc", syslogHeaderValue("abcdef", 3))
+	assert.Equal(t, "a_b_c_d", syslogSDName(`a=b"c]d`))
+}
@@ -0,0 +1,65 @@
+package lager
+
+import (
+	"context"
+
+	"github.com/dapings/lager/common"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	uatomic "go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+const (
+	// the keys of the trace correlation fields added by the context-aware API.
+	TraceIDKey    = common.TraceIDKey
+	SpanIDKey     = common.SpanIDKey
+	TraceFlagsKey = common.TraceFlagsKey
+
+	// the attributes of the span events recorded for the entries.
+	spanEventLevelKey = "log.severity"
+	spanEventScopeKey = "log.scope"
+)
+
+// spanEventLevel the minimum level of the entries recorded as span events, the NoneLevel disables them.
+var spanEventLevel = uatomic.NewInt32(int32(NoneLevel))
+
+// traceFields returns the trace correlation fields of the span carried by the context, if any.
+func traceFields(ctx context.Context) []zap.Field {
+	if ctx == nil {
+		return nil
+	}
+
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+
+	return []zap.Field{
+		zap.String(TraceIDKey, sc.TraceID().String()),
+		zap.String(SpanIDKey, sc.SpanID().String()),
+		zap.String(TraceFlagsKey, sc.TraceFlags().String()),
+	}
+}
+
+// recordSpanEvent records the entry as an event of the span carried by the context,
+// when the span is recording and the level is at or above the span event level.
+func recordSpanEvent(ctx context.Context, scope string, level Level, msg string, fields []zap.Field) {
+	if ctx == nil || !Level(spanEventLevel.Load()).Enabled(level) {
+		return
+	}
+
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	m := fieldsToMap(fields)
+	attrs := make([]attribute.KeyValue, 0, len(m)+2)
+	attrs = append(attrs, attribute.String(spanEventLevelKey, level.String()), attribute.String(spanEventScopeKey, scope))
+	for k, v := range m {
+		attrs = append(attrs, attribute.String(k, fieldValueString(v)))
+	}
+
+	span.AddEvent(msg, trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,96 @@
+package lager
+
+import (
+	"context"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// startSpan starts a recording span, whose ended spans are recorded by the returned recorder.
+func startSpan(t *testing.T) (context.Context, trace.Span, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	
+	ctx, span := provider.Tracer("lager").Start(context.Background(), "op")
+	return ctx, span, recorder
+}
+
+func TestTraceFields(t *testing.T) {
+	assert.Empty(t, traceFields(context.Background()))
+	
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(WithContext(context.Background(), zap.String("request_id", "r1")), sc)
+	
+	assert.Equal(t, []zap.Field{
+		zap.String("request_id", "r1"),
+		zap.String(TraceIDKey, "4bf92f3577b34da6a3ce929d0e0e4736"),
+		zap.String(SpanIDKey, "00f067aa0ba902b7"),
+		zap.String(TraceFlagsKey, "01"),
+	}, contextFields(ctx))
+	assert.Equal(t, []zap.Field{zap.String("request_id", "r1")}, ContextFields(ctx))
+}
+
+func TestTraceCorrelation(t *testing.T) {
+	s := RegisterScope("test-trace", "")
+	buf := configureBuffer(t, DefaultOptions())
+	ctx, span, _ := startSpan(t)
+	defer span.End()
+	
+	s.InfoCtx(ctx, "traced")
+	FromContext(ctx).Info("global")
+	s.Info("not traced")
+	
+	entries := decodeLines(t, buf)
+	require.Len(t, entries, 3)
+	for _, e := range entries[:2] {
+		assert.Equal(t, span.SpanContext().TraceID().String(), e[TraceIDKey], e["msg"])
+		assert.Equal(t, span.SpanContext().SpanID().String(), e[SpanIDKey], e["msg"])
+		assert.Equal(t, "01", e[TraceFlagsKey], e["msg"])
+	}
+	assert.NotContains(t, entries[2], TraceIDKey)
+}
+
+func TestSpanEvents(t *testing.T) {
+	s := RegisterScope("test-span-events", "")
+	configureBuffer(t, DefaultOptions().WithSpanEvents(WarnLevel))
+	ctx, span, recorder := startSpan(t)
+	
+	s.InfoCtx(ctx, "below")
+	s.WarnCtx(ctx, "warned", zap.Int("count", 1))
+	s.ErrorCtx(ctx, "failed")
+	s.Error("without context")
+	span.End()
+	
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	events := spans[0].Events()
+	require.Len(t, events, 2)
+	
+	assert.Equal(t, "warned", events[0].Name)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String(spanEventLevelKey, "warn"),
+		attribute.String(spanEventScopeKey, "test-span-events"),
+		attribute.String("count", "1"),
+	}, events[0].Attributes)
+	assert.Equal(t, "failed", events[1].Name)
+	assert.Contains(t, events[1].Attributes, attribute.String(spanEventLevelKey, "error"))
+	
+	// disabled by default.
+	configureBuffer(t, DefaultOptions())
+	ctx, span, recorder = startSpan(t)
+	s.ErrorCtx(ctx, "not recorded")
+	span.End()
+	assert.Empty(t, recorder.Ended()[0].Events())
+}